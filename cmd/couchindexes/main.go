@@ -0,0 +1,25 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"example.com/fffffefe/lib/model"
+	"example.com/fffffefe/src"
+)
+
+// couchindexes generates the CouchDB index definitions packaged with the chaincode from the couchIndex tags of the assets
+func main() {
+	out := flag.String("out", model.CouchIndexDir, "directory the index definitions are written to")
+	flag.Parse()
+	if err := src.WriteCouchIndexes(*out); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,26 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"example.com/fffffefe/cmd/methodparams/params"
+)
+
+// methodparams generates the table of controller method parameter names used by the contract metadata
+func main() {
+	dir := flag.String("dir", "src", "directory of the controller sources")
+	out := flag.String("out", filepath.Join("src", "fffffefe.params.go"), "file the table is written to")
+	flag.Parse()
+	if err := params.Write(*dir, "Controller", "src", "methodParams", *out); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,97 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+// Package params generates the table of method parameter names which util.RegisterMethods registers. It parses Go
+// sources, so only the generator and the tests checking the table is current import it; the chaincode does not.
+package params
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Parse reads the Go sources in dir and returns the parameter names of the exported methods of the
+// receiver type, by method name. Reflection does not give parameter names, so they are generated from the source.
+func Parse(dir string, receiver string) (map[string][]string, error) {
+	fileSet := token.NewFileSet()
+	packages, err := parser.ParseDir(fileSet, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Error in parsing method parameters: %s", err.Error())
+	}
+	params := make(map[string][]string)
+	for _, pkg := range packages {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				function, ok := decl.(*ast.FuncDecl)
+				if !ok || function.Recv == nil || len(function.Recv.List) != 1 || !function.Name.IsExported() {
+					continue
+				}
+				receiverType := function.Recv.List[0].Type
+				if star, ok := receiverType.(*ast.StarExpr); ok {
+					receiverType = star.X
+				}
+				if ident, ok := receiverType.(*ast.Ident); !ok || ident.Name != receiver {
+					continue
+				}
+				names := []string{}
+				for _, field := range function.Type.Params.List {
+					if len(field.Names) == 0 {
+						names = append(names, fmt.Sprintf("arg%d", len(names)))
+					}
+					for _, name := range field.Names {
+						names = append(names, name.Name)
+					}
+				}
+				params[function.Name.Name] = names
+			}
+		}
+	}
+	return params, nil
+}
+
+// Write generates the Go file declaring the variable variableName in package pkg, holding the
+// parameter names of the methods of receiver found in dir
+func Write(dir string, receiver string, pkg string, variableName string, fileName string) error {
+	params, err := Parse(dir, receiver)
+	if err != nil {
+		return err
+	}
+	methods := make([]string, 0, len(params))
+	for method := range params {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	var buffer bytes.Buffer
+	buffer.WriteString("// Code generated by go generate; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buffer, "package %s\n\n", pkg)
+	fmt.Fprintf(&buffer, "// %s lists the parameter names of the %s methods, which reflection does not give\n", variableName, receiver)
+	fmt.Fprintf(&buffer, "var %s = map[string][]string{\n", variableName)
+	for _, method := range methods {
+		quoted := make([]string, len(params[method]))
+		for i, name := range params[method] {
+			quoted[i] = fmt.Sprintf("%q", name)
+		}
+		fmt.Fprintf(&buffer, "%q: {%s},\n", method, strings.Join(quoted, ", "))
+	}
+	buffer.WriteString("}\n")
+	source, err := format.Source(buffer.Bytes())
+	if err != nil {
+		return fmt.Errorf("Error in writing method parameters: %s", err.Error())
+	}
+	if err := ioutil.WriteFile(fileName, source, 0644); err != nil {
+		return fmt.Errorf("Error in writing method parameters: %s", err.Error())
+	}
+	return nil
+}
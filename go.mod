@@ -7,6 +7,7 @@ require (
 	github.com/creasty/defaults v1.4.0
 	github.com/golang/protobuf v1.3.2
 	github.com/hyperledger/fabric-chaincode-go v0.0.0-20200511190512-bcfeb58dd83a
+	github.com/hyperledger/fabric-contract-api-go v1.1.0
 	github.com/hyperledger/fabric-protos-go v0.0.0-20200506201313-25f6564b9ac4
 	gopkg.in/validator.v2 v2.0.0-20200605151824-2b28d334fa05
 )
@@ -7,6 +7,7 @@ package chaincode
 
 import (
 	"fmt"
+	"strings"
 
 	"example.com/fffffefe/lib/util"
 	"example.com/fffffefe/src"
@@ -19,8 +20,11 @@ type ChainCode struct {
 
 //Init Function Executes only once while initializing or upgrading chaincode
 func (t *ChainCode) Init(stub shim.ChaincodeStubInterface) peer.Response {
-	_, args := stub.GetFunctionAndParameters()
+	function, args := stub.GetFunctionAndParameters()
 	chaincodeController := new(src.Controller)
+	if strings.HasSuffix(function, util.NamedArgsSuffix) {
+		return util.ExecuteMethod(chaincodeController, "Init"+util.NamedArgsSuffix, stub, args)
+	}
 	return util.ExecuteMethod(chaincodeController, "Init", stub, args)
 }
 
@@ -28,6 +32,10 @@ func (t *ChainCode) Init(stub shim.ChaincodeStubInterface) peer.Response {
 func (t *ChainCode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 	function, args := stub.GetFunctionAndParameters()
 	fmt.Println("invoking " + function)
+	// the configuration is only replaced on instantiation or upgrade, never by a plain transaction
+	if strings.EqualFold(strings.TrimSuffix(function, util.NamedArgsSuffix), "Init") {
+		return shim.Error("ExecuteMethod: Init can only be run on instantiation or upgrade")
+	}
 	chaincodeController := new(src.Controller)
 	return util.ExecuteMethod(chaincodeController, function, stub, args)
 }
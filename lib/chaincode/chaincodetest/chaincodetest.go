@@ -6,6 +6,9 @@
 package chaincodetest
 
 import (
+	"testing"
+
+	"example.com/fffffefe/lib/util"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-protos-go/peer"
 )
@@ -23,3 +26,19 @@ func (t *MockChainCode) Init(stub shim.ChaincodeStubInterface) peer.Response {
 func (t *MockChainCode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 	return shim.Success(nil)
 }
+
+// RequireSelfCheck fails the test when check, usually src.SelfCheck, finds problems, reporting each one of them
+func RequireSelfCheck(t testing.TB, check func() error) {
+	t.Helper()
+	err := check()
+	if err == nil {
+		return
+	}
+	if problems, ok := err.(util.Problems); ok {
+		for _, problem := range problems {
+			t.Errorf("Self-check fail. %s \n", problem)
+		}
+		return
+	}
+	t.Errorf("Self-check fail. Error %s \n", err.Error())
+}
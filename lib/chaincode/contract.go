@@ -0,0 +1,97 @@
+//go:build contractapi
+// +build contractapi
+
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"example.com/fffffefe/lib/util"
+	"example.com/fffffefe/src"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+)
+
+// Contract exposes the controller methods as a fabric-contract-api-go contract. It is built with the contractapi
+// build tag.
+// Methods taking a *util.TxContext receive the contract api's transaction context there.
+type Contract struct {
+	contractapi.Contract
+	src.Controller
+}
+
+// GetSupplierPage returns a page of suppliers with the bookmark of the next one in a PagedResult, as ExecuteMethod
+// does, since contract api functions return at most a value and an error
+func (c *Contract) GetSupplierPage(ctx *util.TxContext, pageSize int, bookmark util.Bookmark) (util.PagedResult, error) {
+	suppliers, next, err := c.Controller.GetSupplierPage(ctx, pageSize, bookmark)
+	if err != nil {
+		return util.PagedResult{}, err
+	}
+	return util.PagedResult{Results: suppliers, Bookmark: next}, nil
+}
+
+// argSerializer converts arguments and results as ExecuteMethod does, so that dates, decimals and assets are read,
+// defaulted and validated the same through both entrypoints, and results are always JSON. Neither is checked
+// against the contract api schemas, which only name dates and decimals.
+type argSerializer struct{}
+
+func (s *argSerializer) FromString(param string, fieldType reflect.Type, paramMetadata *metadata.ParameterMetadata, components *metadata.ComponentMetadata) (reflect.Value, error) {
+	return util.ConvertArg(param, fieldType)
+}
+
+func (s *argSerializer) ToString(result reflect.Value, resultType reflect.Type, returns *metadata.ReturnMetadata, components *metadata.ComponentMetadata) (string, error) {
+	resultBytes, err := json.Marshal(result.Interface())
+	if err != nil {
+		return "", fmt.Errorf("Marshalling response Error: %s", err.Error())
+	}
+	return string(resultBytes), nil
+}
+
+// beforeTransaction makes the transaction context current, for code still calling the package level model functions
+func beforeTransaction(ctx *util.TxContext) error {
+	util.SetCurrentContext(ctx)
+	function, _ := ctx.Stub.GetFunctionAndParameters()
+	ctx.Logf("invoking %s", function)
+	return nil
+}
+
+// afterTransaction logs the completion of the transaction
+func afterTransaction(ctx *util.TxContext) error {
+	function, _ := ctx.Stub.GetFunctionAndParameters()
+	ctx.Logf("completed %s", function)
+	return nil
+}
+
+// unknownTransaction is called for functions which are not methods of the controller
+func unknownTransaction(ctx *util.TxContext) error {
+	function, _ := ctx.Stub.GetFunctionAndParameters()
+	return fmt.Errorf("ExecuteMethod: No method found by given name - %s", function)
+}
+
+// NewContractChaincode returns the chaincode serving the controller through the contract api
+func NewContractChaincode() (*contractapi.ContractChaincode, error) {
+	contract := new(Contract)
+	contract.Name = util.ChaincodeName
+	contract.Info = metadata.InfoMetadata{Title: util.ChaincodeName, Version: util.ChaincodeVersion}
+	contract.TransactionContextHandler = new(util.TxContext)
+	contract.BeforeTransaction = beforeTransaction
+	contract.AfterTransaction = afterTransaction
+	contract.UnknownTransaction = unknownTransaction
+
+	chaincode, err := contractapi.NewChaincode(contract)
+	if err != nil {
+		return nil, fmt.Errorf("Error in creating contract chaincode: %s", err.Error())
+	}
+	chaincode.DefaultContract = contract.Name
+	chaincode.TransactionSerializer = new(argSerializer)
+	chaincode.Info.Title = util.ChaincodeName
+	chaincode.Info.Version = util.ChaincodeVersion
+	return chaincode, nil
+}
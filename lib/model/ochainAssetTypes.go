@@ -0,0 +1,120 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// assetTypeRegistry maps the AssetType of ledger records, e.g. fffffefe.Supplier, to the Go types of the assets.
+// The Go type names, e.g. Supplier, are accepted as well.
+var assetTypeRegistry = struct {
+	byName   map[string]reflect.Type
+	byType   map[reflect.Type]string
+	problems []string
+}{byName: map[string]reflect.Type{}, byType: map[reflect.Type]string{}}
+
+// RegisterAssetTypes registers the types of the assets stored in the ledger under the AssetType their records
+// carry, which is the final tag of their AssetType field. Types which cannot be registered are reported by
+// CheckAssetTypes.
+func RegisterAssetTypes(prototypes ...interface{}) {
+	for _, prototype := range prototypes {
+		assetType := structType(reflect.TypeOf(prototype))
+		name := finalAssetType(assetType)
+		if name == "" {
+			assetTypeRegistry.problems = append(assetTypeRegistry.problems, fmt.Sprintf("%s: no AssetType field with a final tag, it cannot be registered", assetType))
+			continue
+		}
+		if existing, ok := assetTypeRegistry.byName[name]; ok && existing != assetType {
+			assetTypeRegistry.problems = append(assetTypeRegistry.problems, fmt.Sprintf("%s: AssetType %s is already registered for %s", assetType, name, existing))
+			continue
+		}
+		assetTypeRegistry.byName[name] = assetType
+		assetTypeRegistry.byName[assetType.Name()] = assetType
+		assetTypeRegistry.byType[assetType] = name
+	}
+}
+
+// RegisteredAssetTypes returns the Go type names of the registered asset types, sorted
+func RegisteredAssetTypes() []string {
+	names := make([]string, 0, len(assetTypeRegistry.byType))
+	for assetType := range assetTypeRegistry.byType {
+		names = append(names, assetType.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewAsset returns a pointer to a new asset of the registered type, given by AssetType or Go type name
+func NewAsset(name string) (interface{}, error) {
+	assetType, ok := assetTypeRegistry.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("Error in getting: unsupported asset type %s", name)
+	}
+	return reflect.New(assetType).Interface(), nil
+}
+
+// CheckAssetTypes reports the problems of the registered asset types, see CheckAssetType
+func CheckAssetTypes() []string {
+	problems := append([]string{}, assetTypeRegistry.problems...)
+	for _, name := range RegisteredAssetTypes() {
+		problems = append(problems, CheckAssetType(reflect.New(assetTypeRegistry.byName[name]).Interface())...)
+	}
+	return problems
+}
+
+// structType returns the struct type of an asset given as value, pointer or slice of them
+func structType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t
+}
+
+// finalAssetType returns the final tag of the AssetType field of t, or "" when it has none
+func finalAssetType(t reflect.Type) string {
+	if t.Kind() != reflect.Struct {
+		return ""
+	}
+	field, ok := t.FieldByName("AssetType")
+	if !ok {
+		return ""
+	}
+	return field.Tag.Get("final")
+}
+
+// assetTypeOf returns the AssetType which the records of the assets of type t carry. Types which are not
+// registered are looked up by their final tag.
+func assetTypeOf(t reflect.Type) (string, error) {
+	assetType := structType(t)
+	if name, ok := assetTypeRegistry.byType[assetType]; ok {
+		return name, nil
+	}
+	if name := finalAssetType(assetType); name != "" {
+		return name, nil
+	}
+	return "", fmt.Errorf("%s is not an asset type", t)
+}
+
+// GetAnyById reads the asset with the given id, decoded into the registered type of its AssetType. It returns a
+// pointer to the asset, e.g. *Supplier.
+func (l *Ledger) GetAnyById(id string) (interface{}, error) {
+	record, err := l.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	name, _ := record.(map[string]interface{})["AssetType"].(string)
+	asset, err := NewAsset(name)
+	if err != nil {
+		return nil, fmt.Errorf("Error in getting: unsupported asset type %s for Id %s", name, id)
+	}
+	if _, err := l.Get(id, asset); err != nil {
+		return nil, err
+	}
+	return asset, nil
+}
@@ -0,0 +1,203 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/creasty/defaults"
+)
+
+const (
+	// ConfigObjectType is the composite key object type under which the chaincode configuration is stored.
+	// Composite keys are not returned by plain range queries, so the config never shows up as an asset.
+	ConfigObjectType = "Config"
+	// ValidationStrict re-validates assets when they are read back from the ledger
+	ValidationStrict = "strict"
+	// ValidationLenient validates assets only when they are written to the ledger
+	ValidationLenient = "lenient"
+	// SoftDeletedField is the field set on a record which has been soft deleted
+	SoftDeletedField = "IsDeleted"
+)
+
+// Features holds the feature toggles of the chaincode
+type Features struct {
+	SoftDelete bool `json:"SoftDelete"`
+	Events     bool `json:"Events"`
+}
+
+// Config is the chaincode configuration document supplied to Init
+type Config struct {
+	AdminMSPs     []string                     `json:"AdminMSPs"`
+	RegulatorMSPs []string                     `json:"RegulatorMSPs"`
+	Features      Features                     `json:"Features"`
+	Validation    string                       `json:"Validation" default:"strict"`
+	QueryAccess   map[string][]string          `json:"QueryAccess,omitempty"`
+	Seed          map[string][]json.RawMessage `json:"Seed,omitempty"`
+}
+
+func (l *Ledger) getConfigKey() (string, error) {
+	return l.ctx.Stub.CreateCompositeKey(ConfigObjectType, []string{})
+}
+
+// ParseConfig constructs and validates a Config from the given json document.
+// An empty document yields the default configuration.
+func ParseConfig(inputString string) (*Config, error) {
+	config := new(Config)
+	if err := defaults.Set(config); err != nil {
+		return nil, fmt.Errorf("Error in parsing config: failure in default setting %s", err.Error())
+	}
+	if inputString != "" {
+		if err := json.Unmarshal([]byte(inputString), config); err != nil {
+			return nil, fmt.Errorf("Error in parsing config: unmarshalling error %s", err.Error())
+		}
+	}
+	if err := ValidateConfig(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ValidateConfig checks the given config for invalid values
+func ValidateConfig(config *Config) error {
+	if err := validateMSPList("admin", config.AdminMSPs); err != nil {
+		return err
+	}
+	if err := validateMSPList("regulator", config.RegulatorMSPs); err != nil {
+		return err
+	}
+	for mspID := range config.QueryAccess {
+		if mspID == "" {
+			return fmt.Errorf("Error in validating config: QueryAccess MSP id cannot be empty")
+		}
+	}
+	if config.Validation != ValidationStrict && config.Validation != ValidationLenient {
+		return fmt.Errorf("Error in validating config: Validation must be %s or %s, given %s", ValidationStrict, ValidationLenient, config.Validation)
+	}
+	return nil
+}
+
+func validateMSPList(role string, mspIDs []string) error {
+	seen := make(map[string]bool)
+	for _, mspID := range mspIDs {
+		if mspID == "" {
+			return fmt.Errorf("Error in validating config: %s MSP id cannot be empty", role)
+		}
+		if seen[mspID] {
+			return fmt.Errorf("Error in validating config: %s MSP %s is listed more than once", role, mspID)
+		}
+		seen[mspID] = true
+	}
+	return nil
+}
+
+// GetConfig reads the chaincode configuration from the ledger, once per transaction, keeping it in the Config of
+// the transaction context.
+// The default configuration is returned if none has been saved yet.
+func (l *Ledger) GetConfig() (*Config, error) {
+	if cached, ok := l.ctx.Config.(*Config); ok {
+		config := *cached
+		return &config, nil
+	}
+	key, err := l.getConfigKey()
+	if err != nil {
+		return nil, fmt.Errorf("Error in getting config: %s", err.Error())
+	}
+	configAsBytes, err := l.ctx.Stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("Error in getting config: %s", err.Error())
+	}
+	config := new(Config)
+	if configAsBytes == nil {
+		if config, err = ParseConfig(""); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(configAsBytes, config); err != nil {
+		return nil, fmt.Errorf("Error in getting config: unmarshalling error %s", err.Error())
+	}
+	l.ctx.Config = config
+	copied := *config
+	return &copied, nil
+}
+
+// ConfigExists reports whether a configuration has been saved, i.e. whether the chaincode has been initialised
+func (l *Ledger) ConfigExists() (bool, error) {
+	key, err := l.getConfigKey()
+	if err != nil {
+		return false, fmt.Errorf("Error in getting config: %s", err.Error())
+	}
+	configAsBytes, err := l.ctx.Stub.GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("Error in getting config: %s", err.Error())
+	}
+	return configAsBytes != nil, nil
+}
+
+// SaveConfig validates the configuration and writes it to the ledger. Seed assets are not persisted.
+func (l *Ledger) SaveConfig(config *Config) error {
+	if err := ValidateConfig(config); err != nil {
+		return err
+	}
+	toSave := *config
+	toSave.Seed = nil
+	configAsBytes, err := json.Marshal(toSave)
+	if err != nil {
+		return fmt.Errorf("Error in saving config: marshal error %s", err.Error())
+	}
+	key, err := l.getConfigKey()
+	if err != nil {
+		return fmt.Errorf("Error in saving config: %s", err.Error())
+	}
+	if err := l.ctx.Stub.PutState(key, configAsBytes); err != nil {
+		return fmt.Errorf("Error in saving config: transaction error %s", err.Error())
+	}
+	l.ctx.Config = &toSave
+	return nil
+}
+
+// IsAdmin reports whether the submitter of the current transaction belongs to one of the admin MSPs
+func (l *Ledger) IsAdmin() (bool, error) {
+	config, err := l.GetConfig()
+	if err != nil {
+		return false, err
+	}
+	return l.callerInMSPs(config.AdminMSPs)
+}
+
+// IsRegulator reports whether the submitter of the current transaction belongs to one of the regulator MSPs
+func (l *Ledger) IsRegulator() (bool, error) {
+	config, err := l.GetConfig()
+	if err != nil {
+		return false, err
+	}
+	return l.callerInMSPs(config.RegulatorMSPs)
+}
+
+func (l *Ledger) callerInMSPs(mspIDs []string) (bool, error) {
+	callerMSP, err := l.ctx.CreatorMSPID()
+	if err != nil {
+		return false, err
+	}
+	for _, mspID := range mspIDs {
+		if mspID == callerMSP {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CheckAdmin returns an error unless the submitter of the current transaction is an admin
+func (l *Ledger) CheckAdmin() error {
+	admin, err := l.IsAdmin()
+	if err != nil {
+		return fmt.Errorf("Error in checking admin: %s", err.Error())
+	}
+	if !admin {
+		return fmt.Errorf("Access denied: caller is not a member of an admin MSP")
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// CouchIndexTag names the CouchDB indexes a field belongs to, e.g. couchIndex:"byLicense". A field may be in
+	// several indexes separated by commas. Fields sharing an index name form one index, in struct field order.
+	CouchIndexTag = "couchIndex"
+	// CouchIndexDir is where Fabric expects the index definitions, relative to the chaincode root
+	CouchIndexDir = "META-INF/statedb/couchdb/indexes"
+)
+
+// CouchIndexFields lists the fields of a CouchDB index
+type CouchIndexFields struct {
+	Fields []string `json:"fields"`
+}
+
+// CouchIndex is a CouchDB index definition as packaged with the chaincode
+type CouchIndex struct {
+	Index CouchIndexFields `json:"index"`
+	Ddoc  string           `json:"ddoc"`
+	Name  string           `json:"name"`
+	Type  string           `json:"type"`
+}
+
+func assetStructType(prototype interface{}) reflect.Type {
+	assetType := reflect.TypeOf(prototype)
+	for assetType.Kind() == reflect.Ptr {
+		assetType = assetType.Elem()
+	}
+	return assetType
+}
+
+// indexedFields returns the fields of each index declared on the asset's fields, by index name
+func indexedFields(assetType reflect.Type) map[string][]string {
+	indexes := make(map[string][]string)
+	for i := 0; i < assetType.NumField(); i++ {
+		field := assetType.Field(i)
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		for _, name := range strings.Split(field.Tag.Get(CouchIndexTag), ",") {
+			if name = strings.TrimSpace(name); name != "" && jsonName != "" {
+				indexes[name] = append(indexes[name], jsonName)
+			}
+		}
+	}
+	return indexes
+}
+
+// CouchIndexes derives the CouchDB indexes of an asset from its couchIndex tags. Every index starts with AssetType,
+// which every query selects on. Indexes are named after the asset type and ordered by name.
+func CouchIndexes(prototype interface{}) []CouchIndex {
+	assetType := assetStructType(prototype)
+	indexes := indexedFields(assetType)
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := []CouchIndex{}
+	for _, name := range names {
+		indexName := assetType.Name() + "_" + name
+		result = append(result, CouchIndex{
+			Index: CouchIndexFields{Fields: append([]string{"AssetType"}, indexes[name]...)},
+			Ddoc:  indexName + "Doc",
+			Name:  indexName,
+			Type:  "json",
+		})
+	}
+	return result
+}
+
+// CouchIndexFiles returns the index definition files of the given assets, by file name
+func CouchIndexFiles(prototypes ...interface{}) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	for _, prototype := range prototypes {
+		for _, index := range CouchIndexes(prototype) {
+			indexAsBytes, err := json.MarshalIndent(index, "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("Error in generating index %s: marshal error %s", index.Name, err.Error())
+			}
+			files[index.Name+".json"] = append(indexAsBytes, '\n')
+		}
+	}
+	return files, nil
+}
+
+// WriteCouchIndexes replaces the index definition files in dir with the indexes of the given assets
+func WriteCouchIndexes(dir string, prototypes ...interface{}) error {
+	files, err := CouchIndexFiles(prototypes...)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Error in writing indexes: %s", err.Error())
+	}
+	existing, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("Error in writing indexes: %s", err.Error())
+	}
+	for _, path := range existing {
+		if _, ok := files[filepath.Base(path)]; !ok {
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("Error in writing indexes: %s", err.Error())
+			}
+		}
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			return fmt.Errorf("Error in writing indexes: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// checkSortIndexed verifies that some index of the asset can serve the sort: the sort fields must follow each
+// other in the index, and the index fields before them must be selected with eq filters.
+func checkSortIndexed(assetType reflect.Type, sortFields []string, equalities map[string]bool) error {
+	for _, fields := range indexedFields(assetType) {
+		for start := 0; start+len(sortFields) <= len(fields); start++ {
+			matches := true
+			for i, field := range sortFields {
+				matches = matches && fields[start+i] == field
+			}
+			for _, field := range fields[:start] {
+				matches = matches && equalities[field]
+			}
+			if matches {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("Error in query: no index of %s can sort on %s", assetType.Name(), strings.Join(sortFields, ", "))
+}
@@ -0,0 +1,153 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/fffffefe/lib/util/date"
+	"example.com/fffffefe/lib/util/decimal"
+)
+
+const (
+	// IdSeparator separates the parts of a composite id, e.g. r1~42 for an asset keyed by retailer and order number
+	IdSeparator = "~"
+	// GeneratedIdTag is the value of the id tag of a string field which Save fills, when it is empty, with an id
+	// derived from the transaction id
+	GeneratedIdTag = "generated"
+
+	// txSequenceValue is the context value numbering the ids generated within one transaction
+	txSequenceValue = "model.txSequence"
+)
+
+var (
+	idDateType    = reflect.TypeOf(date.Date{})
+	idDecimalType = reflect.TypeOf(decimal.Decimal{})
+	idTimeType    = reflect.TypeOf(time.Time{})
+)
+
+// idFields returns the indexes of the fields of t tagged id, in declaration order
+func idFields(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("id"); ok {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// getID returns the ledger key of the asset. An asset with one id field is keyed by its encoded value, an asset
+// with several by the composite of their values in declaration order.
+func getID(obj interface{}) (string, error) {
+	objValue := reflect.ValueOf(obj).Elem()
+	fields := idFields(objValue.Type())
+	if len(fields) == 0 {
+		return "", errors.New("Id tag is not set")
+	}
+	parts := make([]interface{}, len(fields))
+	for i, field := range fields {
+		parts[i] = objValue.Field(field).Interface()
+	}
+	return CompositeId(parts...)
+}
+
+// CompositeId returns the ledger key of an asset whose id fields have the given values, e.g. to get an asset
+// keyed by retailer and order number. A single value is encoded alone; several are escaped and joined by
+// IdSeparator, and none of them may be empty.
+func CompositeId(parts ...interface{}) (string, error) {
+	if len(parts) == 1 {
+		return encodeIdPart(reflect.ValueOf(parts[0]))
+	}
+	encoded := make([]string, len(parts))
+	for i, part := range parts {
+		text, err := encodeIdPart(reflect.ValueOf(part))
+		if err != nil {
+			return "", err
+		}
+		if text == "" {
+			return "", fmt.Errorf("part %d of the composite id is empty", i+1)
+		}
+		encoded[i] = strings.Replace(strings.Replace(text, "%", "%25", -1), IdSeparator, "%7E", -1)
+	}
+	return strings.Join(encoded, IdSeparator), nil
+}
+
+// encodeIdPart writes an id value the same way on every endorser: numbers in decimal, dates as YYYY-MM-DD and
+// times in RFC 3339 UTC
+func encodeIdPart(value reflect.Value) (string, error) {
+	if !value.IsValid() {
+		return "", errors.New("id value is nil")
+	}
+	switch value.Type() {
+	case idDateType:
+		return value.Interface().(date.Date).Format(date.CustomDateLayout), nil
+	case idDecimalType:
+		return value.Interface().(decimal.Decimal).String(), nil
+	case idTimeType:
+		return value.Interface().(time.Time).UTC().Format(time.RFC3339Nano), nil
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("id values of type %s cannot be encoded", value.Type())
+}
+
+// checkIdFields reports the id tags of t which Save cannot use
+func checkIdFields(t reflect.Type) []string {
+	var problems []string
+	fields := idFields(t)
+	for _, index := range fields {
+		field := t.Field(index)
+		if _, err := encodeIdPart(reflect.Zero(field.Type)); err != nil {
+			problems = append(problems, fmt.Sprintf("id field %s: %s", field.Name, err.Error()))
+		}
+		switch tag := field.Tag.Get("id"); tag {
+		case "true":
+		case GeneratedIdTag:
+			if field.Type.Kind() != reflect.String {
+				problems = append(problems, fmt.Sprintf("generated id field %s is not a string", field.Name))
+			}
+			if len(fields) > 1 {
+				problems = append(problems, fmt.Sprintf("generated id field %s is part of a composite id", field.Name))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("id field %s is tagged %q, expected \"true\" or %q", field.Name, tag, GeneratedIdTag))
+		}
+	}
+	return problems
+}
+
+// NextTxScopedId returns a new id, unique within the ledger, derived from the transaction id. The ids generated
+// within one transaction are numbered, so that every endorser generates the same ones.
+func (l *Ledger) NextTxScopedId(prefix string) string {
+	next, _ := l.ctx.Value(txSequenceValue).(int)
+	l.ctx.SetValue(txSequenceValue, next+1)
+	return fmt.Sprintf("%s-%s-%d", prefix, l.ctx.Stub.GetTxID(), next)
+}
+
+// assignGeneratedId fills an empty id field tagged generated with an id prefixed by the asset type
+func (l *Ledger) assignGeneratedId(obj interface{}) {
+	objValue := reflect.ValueOf(obj).Elem()
+	for _, index := range idFields(objValue.Type()) {
+		field := objValue.Field(index)
+		if objValue.Type().Field(index).Tag.Get("id") == GeneratedIdTag && field.Kind() == reflect.String && field.String() == "" {
+			field.SetString(l.NextTxScopedId(objValue.Type().Name()))
+		}
+	}
+}
@@ -0,0 +1,198 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package model
+
+import (
+	"example.com/fffffefe/lib/util"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Ledger reads and writes assets within one transaction context
+type Ledger struct {
+	ctx *util.TxContext
+}
+
+// For returns the ledger of the transaction context
+func For(ctx *util.TxContext) *Ledger {
+	return &Ledger{ctx: ctx}
+}
+
+// Context returns the transaction context of the ledger
+func (l *Ledger) Context() *util.TxContext {
+	return l.ctx
+}
+
+// current is the ledger of the transaction run by the global util.Stub. The package level functions below use it
+// so that code not taking a *util.TxContext yet keeps working; new code calls For with its context instead.
+func current() *Ledger {
+	return For(util.CurrentContext())
+}
+
+// Save writes the asset to the ledger
+func Save(args ...interface{}) (interface{}, error) {
+	return current().Save(args...)
+}
+
+// NextTxScopedId returns a new id, unique within the ledger, derived from the transaction id
+func NextTxScopedId(prefix string) string {
+	return current().NextTxScopedId(prefix)
+}
+
+func GenerateCompositeKey(indexName string, attributes []string) (string, error) {
+	return current().GenerateCompositeKey(indexName, attributes)
+}
+
+func GetByCompositeKey(key string, columns []string, index int) (interface{}, error) {
+	return current().GetByCompositeKey(key, columns, index)
+}
+
+// PutIndexEntry writes a composite key entry without value, to look assets up with GetIdsByCompositeKey
+func PutIndexEntry(indexName string, attributes []string) error {
+	return current().PutIndexEntry(indexName, attributes)
+}
+
+// DelIndexEntry removes a composite key entry written with PutIndexEntry
+func DelIndexEntry(indexName string, attributes []string) error {
+	return current().DelIndexEntry(indexName, attributes)
+}
+
+// GetIdsByCompositeKey returns the attribute at position index of every composite key matching the partial key
+func GetIdsByCompositeKey(indexName string, columns []string, index int) ([]string, error) {
+	return current().GetIdsByCompositeKey(indexName, columns, index)
+}
+
+func GetTransactionId() string {
+	return current().GetTransactionId()
+}
+
+func GetTransactionTimestamp() (*timestamp.Timestamp, error) {
+	return current().GetTransactionTimestamp()
+}
+
+func GetChannelID() string {
+	return current().GetChannelID()
+}
+
+func GetCreator() ([]byte, error) {
+	return current().GetCreator()
+}
+
+func GetSignedProposal() (*peer.SignedProposal, error) {
+	return current().GetSignedProposal()
+}
+
+func GetArgs() [][]byte {
+	return current().GetArgs()
+}
+
+func GetStringArgs() []string {
+	return current().GetStringArgs()
+}
+
+func GetNetworkStub() shim.ChaincodeStubInterface {
+	return current().GetNetworkStub()
+}
+
+// SetEvent emits a chaincode event with the json encoded payload, if events are enabled in the config
+func SetEvent(name string, payload interface{}) error {
+	return current().SetEvent(name, payload)
+}
+
+func Get(Id string, result ...interface{}) (interface{}, error) {
+	return current().Get(Id, result...)
+}
+
+// GetAnyById reads the asset with the given id, decoded into the registered type of its AssetType
+func GetAnyById(id string) (interface{}, error) {
+	return current().GetAnyById(id)
+}
+
+// Update the asset to the ledger
+func Update(args ...interface{}) (interface{}, error) {
+	return current().Update(args...)
+}
+
+// Delete deletes the asset from the ledger
+func Delete(Id string) (interface{}, error) {
+	return current().Delete(Id)
+}
+
+// Query runs the given transaction on the peer
+func Query(queryString string) ([]interface{}, error) {
+	return current().Query(queryString)
+}
+
+// GetByRange gets all the assets with key between the provided range
+func GetByRange(startKey string, endKey string, asset ...interface{}) ([]map[string]interface{}, error) {
+	return current().GetByRange(startKey, endKey, asset...)
+}
+
+// GetPageByRange reads a page of the assets with keys in the range, returning the start key of the next page
+func GetPageByRange(startKey string, endKey string, pageSize int, assets interface{}) (string, error) {
+	return current().GetPageByRange(startKey, endKey, pageSize, assets)
+}
+
+// GetHistoryByID gets the history of an asset from the ledger
+func GetHistoryByID(Id string) ([]interface{}, error) {
+	return current().GetHistoryByID(Id)
+}
+
+// CheckTransition verifies that the status change of the asset, compared to the ledger, is allowed by its state machine
+func CheckTransition(obj interface{}) error {
+	return current().CheckTransition(obj)
+}
+
+// GetTransitionsByID returns the audit trail of status changes of an asset, oldest first
+func GetTransitionsByID(Id string) ([]TransitionRecord, error) {
+	return current().GetTransitionsByID(Id)
+}
+
+// GetConfig reads the chaincode configuration from the ledger
+func GetConfig() (*Config, error) {
+	return current().GetConfig()
+}
+
+// SaveConfig validates the configuration and writes it to the ledger
+func SaveConfig(config *Config) error {
+	return current().SaveConfig(config)
+}
+
+// IsAdmin reports whether the submitter of the current transaction belongs to one of the admin MSPs
+func IsAdmin() (bool, error) {
+	return current().IsAdmin()
+}
+
+// IsRegulator reports whether the submitter of the current transaction belongs to one of the regulator MSPs
+func IsRegulator() (bool, error) {
+	return current().IsRegulator()
+}
+
+// CheckAdmin returns an error unless the submitter of the current transaction is an admin
+func CheckAdmin() error {
+	return current().CheckAdmin()
+}
+
+// ForEachAsset calls fn with every live asset of the given type, one at a time
+func ForEachAsset(assetType string, fn func(record map[string]interface{}) error) error {
+	return current().ForEachAsset(assetType, fn)
+}
+
+// Report computes the aggregates described by spec
+func Report(spec ReportSpec) ([]ReportRow, error) {
+	return current().Report(spec)
+}
+
+// QueryAssets runs a structured query over the assets of the prototype's type
+func QueryAssets(spec QuerySpec, prototype interface{}) ([]interface{}, error) {
+	return current().QueryAssets(spec, prototype)
+}
+
+// CanQuery reports whether the caller may query the given asset type
+func CanQuery(assetType string) (bool, error) {
+	return current().CanQuery(assetType)
+}
@@ -21,35 +21,18 @@ import (
 	"github.com/hyperledger/fabric-protos-go/peer"
 )
 
-func getID(obj interface{}) (string, error) {
-	objValue := reflect.ValueOf(obj).Elem()
-	objType := objValue.Type()
-
-	for i := 0; i < objType.NumField(); i++ {
-		structField := objType.Field(i)
-		_, ok := structField.Tag.Lookup("id")
-		if ok {
-			idValue := objValue.Field(i)
-			idValInterface := idValue
-			idString := fmt.Sprintf("%v", idValInterface)
-			// fmt.Println(idString, reflect.TypeOf(idString))
-			return idString, nil
-		}
-	}
-	return "", errors.New("Id tag is not set")
-}
-
 // Save writes the asset to the ledger
-func Save(args ...interface{}) (interface{}, error) {
-	stub := util.Stub
+func (l *Ledger) Save(args ...interface{}) (interface{}, error) {
+	stub := l.ctx.Stub
 	obj := args[0]
 
+	l.assignGeneratedId(obj)
 	id, idErr := getID(obj)
 	if idErr != nil {
 		return nil, fmt.Errorf("Error in getting Id. Id is mandatory. Error %s", idErr.Error())
 	}
 
-	_, err := Get(id)
+	_, err := l.Get(id)
 	if err == nil {
 		return nil, fmt.Errorf("Error in saving: asset already exist in ledger with Id %s ", id)
 	}
@@ -59,6 +42,10 @@ func Save(args ...interface{}) (interface{}, error) {
 		return nil, fmt.Errorf("AssetType is missing or resetting is a problem %s", err.Error())
 	}
 
+	if err := checkInitialState(obj); err != nil {
+		return nil, err
+	}
+
 	errValidation := validators.ValidateStruct(obj)
 	if errValidation != nil {
 		fmt.Println("Validation Failed")
@@ -87,8 +74,8 @@ func Save(args ...interface{}) (interface{}, error) {
 	return obj, nil
 }
 
-func GenerateCompositeKey(indexName string, attributes []string) (string, error) {
-	stub := util.Stub
+func (l *Ledger) GenerateCompositeKey(indexName string, attributes []string) (string, error) {
+	stub := l.ctx.Stub
 	if len(attributes) == 0 {
 		const errorMessage = "Attributes param is expected to be an array of string"
 		return "", fmt.Errorf(errorMessage)
@@ -101,9 +88,9 @@ func GenerateCompositeKey(indexName string, attributes []string) (string, error)
 	return compositeKey, nil
 }
 
-func GetByCompositeKey(key string, columns []string, index int) (interface{}, error) {
+func (l *Ledger) GetByCompositeKey(key string, columns []string, index int) (interface{}, error) {
 
-	stub := util.Stub
+	stub := l.ctx.Stub
 
 	resultsIterator, err := stub.GetStateByPartialCompositeKey(key, columns)
 	if err != nil {
@@ -153,40 +140,120 @@ func GetByCompositeKey(key string, columns []string, index int) (interface{}, er
 	return result, nil
 }
 
-func GetTransactionId() string {
-	return util.Stub.GetTxID()
+// PutIndexEntry writes a composite key entry without value, to look assets up with GetIdsByCompositeKey
+func (l *Ledger) PutIndexEntry(indexName string, attributes []string) error {
+	compositeKey, err := l.GenerateCompositeKey(indexName, attributes)
+	if err != nil {
+		return err
+	}
+	if err := l.ctx.Stub.PutState(compositeKey, []byte{0x00}); err != nil {
+		return fmt.Errorf("Error in saving index %s: transaction error %s", indexName, err.Error())
+	}
+	return nil
 }
 
-func GetTransactionTimestamp() (*timestamp.Timestamp, error) {
-	return util.Stub.GetTxTimestamp()
+// DelIndexEntry removes a composite key entry written with PutIndexEntry
+func (l *Ledger) DelIndexEntry(indexName string, attributes []string) error {
+	compositeKey, err := l.GenerateCompositeKey(indexName, attributes)
+	if err != nil {
+		return err
+	}
+	if err := l.ctx.Stub.DelState(compositeKey); err != nil {
+		return fmt.Errorf("Error in deleting index %s: transaction error %s", indexName, err.Error())
+	}
+	return nil
 }
 
-func GetChannelID() string {
-	return util.Stub.GetChannelID()
+// GetIdsByCompositeKey returns the attribute at position index of every composite key matching the partial key
+func (l *Ledger) GetIdsByCompositeKey(indexName string, columns []string, index int) ([]string, error) {
+	stub := l.ctx.Stub
+	resultsIterator, err := stub.GetStateByPartialCompositeKey(indexName, columns)
+	if err != nil {
+		return nil, fmt.Errorf("Error in getting by index %s: %s", indexName, err.Error())
+	}
+	defer resultsIterator.Close()
+	var ids []string
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("Error in getting by index %s: iteration error %s", indexName, err.Error())
+		}
+		_, compositeKeyParts, err := stub.SplitCompositeKey(queryResult.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Error in getting by index %s: %s", indexName, err.Error())
+		}
+		if index >= len(compositeKeyParts) {
+			return nil, fmt.Errorf("Error in getting by index %s: key has no attribute %d", indexName, index)
+		}
+		ids = append(ids, compositeKeyParts[index])
+	}
+	return ids, nil
+}
+
+func (l *Ledger) GetTransactionId() string {
+	return l.ctx.Stub.GetTxID()
+}
+
+func (l *Ledger) GetTransactionTimestamp() (*timestamp.Timestamp, error) {
+	return l.ctx.Stub.GetTxTimestamp()
+}
+
+func (l *Ledger) GetChannelID() string {
+	return l.ctx.Stub.GetChannelID()
+}
+
+func (l *Ledger) GetCreator() ([]byte, error) {
+	return l.ctx.Stub.GetCreator()
+}
+
+func (l *Ledger) GetSignedProposal() (*peer.SignedProposal, error) {
+	return l.ctx.Stub.GetSignedProposal()
+}
+
+func (l *Ledger) GetArgs() [][]byte {
+	return l.ctx.Stub.GetArgs()
 }
 
-func GetCreator() ([]byte, error) {
-	return util.Stub.GetCreator()
+func (l *Ledger) GetStringArgs() []string {
+	return l.ctx.Stub.GetStringArgs()
 }
 
-func GetSignedProposal() (*peer.SignedProposal, error) {
-	return util.Stub.GetSignedProposal()
+func (l *Ledger) GetNetworkStub() shim.ChaincodeStubInterface {
+	return l.ctx.Stub
 }
 
-func GetArgs() [][]byte {
-	return util.Stub.GetArgs()
+// SetEvent emits a chaincode event with the json encoded payload, if events are enabled in the config
+func (l *Ledger) SetEvent(name string, payload interface{}) error {
+	config, err := l.GetConfig()
+	if err != nil {
+		return err
+	}
+	if !config.Features.Events {
+		return nil
+	}
+	payloadAsBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("Error in setting event %s: marshal error %s", name, err.Error())
+	}
+	if err := l.ctx.Stub.SetEvent(name, payloadAsBytes); err != nil {
+		return fmt.Errorf("Error in setting event %s: %s", name, err.Error())
+	}
+	return nil
 }
 
-func GetStringArgs() []string {
-	return util.Stub.GetStringArgs()
+// isCompositeKey reports whether key lies in the composite key namespace, which the peer
+// excludes from range queries but some stubs (e.g. shimtest.MockStub) do not
+func isCompositeKey(key string) bool {
+	return strings.HasPrefix(key, "\x00")
 }
 
-func GetNetworkStub() shim.ChaincodeStubInterface {
-	return util.GetStub()
+func isSoftDeleted(record map[string]interface{}) bool {
+	deleted, _ := record[SoftDeletedField].(bool)
+	return deleted
 }
 
-func Get(Id string, result ...interface{}) (interface{}, error) {
-	stub := util.Stub
+func (l *Ledger) Get(Id string, result ...interface{}) (interface{}, error) {
+	stub := l.ctx.Stub
 
 	assetAsBytes, _ := stub.GetState(Id)
 	if assetAsBytes == nil {
@@ -198,22 +265,31 @@ func Get(Id string, result ...interface{}) (interface{}, error) {
 	if unmarshalError != nil {
 		return nil, fmt.Errorf("Error in getting: marshalling error %s", unmarshalError.Error())
 	}
-	assetTypeFromLedgerString := genericResult.(map[string]interface{})["AssetType"]
-	assetTypeFromLedger := strings.Split(assetTypeFromLedgerString.(string), ".")[1]
+	if isSoftDeleted(genericResult.(map[string]interface{})) {
+		return nil, fmt.Errorf("Error in getting: Asset with Id %s does not exists", Id)
+	}
+	assetTypeFromLedger, _ := genericResult.(map[string]interface{})["AssetType"].(string)
 	if len(result) > 0 {
-		inputAssetTypeString := reflect.ValueOf(result[0]).Elem().Type().String()
-		inputAssetType := strings.Split(inputAssetTypeString, ".")[1]
+		inputAssetType, err := assetTypeOf(reflect.TypeOf(result[0]))
+		if err != nil {
+			return nil, fmt.Errorf("Error in getting: %s", err.Error())
+		}
 		if inputAssetType != assetTypeFromLedger {
-			return nil, fmt.Errorf("No Asset %s exist with id %s", inputAssetType, Id)
+			return nil, fmt.Errorf("No Asset %s exist with id %s", structType(reflect.TypeOf(result[0])).Name(), Id)
 		}
 		unmarshalError := json.Unmarshal(assetAsBytes, result[0])
 		if unmarshalError != nil {
 			return nil, fmt.Errorf("Error in getting: marshalling error %s", unmarshalError.Error())
 		}
-		errValidation := validators.ValidateStruct(result[0])
-		if errValidation != nil {
-			fmt.Println("Validation Failed")
-			return nil, fmt.Errorf("Error in retrieving asset: Asset %v error %s", result[0], errValidation.Error())
+		config, err := l.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+		if config.Validation == ValidationStrict {
+			errValidation := validators.ValidateStruct(result[0])
+			if errValidation != nil {
+				return nil, fmt.Errorf("Error in retrieving asset: Asset %v error %s", result[0], errValidation.Error())
+			}
 		}
 		return result[0], nil
 	}
@@ -221,8 +297,8 @@ func Get(Id string, result ...interface{}) (interface{}, error) {
 }
 
 // Update the asset to the ledger
-func Update(args ...interface{}) (interface{}, error) {
-	stub := util.Stub
+func (l *Ledger) Update(args ...interface{}) (interface{}, error) {
+	stub := l.ctx.Stub
 
 	obj := args[0]
 	id, idErr := getID(obj)
@@ -234,8 +310,17 @@ func Update(args ...interface{}) (interface{}, error) {
 	if assetAsBytes == nil {
 		return nil, fmt.Errorf("Error in updating: Unable to get the asset from ledger with ID %s", id)
 	}
+	var existing map[string]interface{}
+	if err := json.Unmarshal(assetAsBytes, &existing); err == nil && isSoftDeleted(existing) {
+		return nil, fmt.Errorf("Error in updating: Unable to get the asset from ledger with ID %s", id)
+	}
 
-	err := util.SetAssetType(obj)
+	transition, from, to, err := l.findTransition(obj, assetAsBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = util.SetAssetType(obj)
 	if err != nil {
 		return nil, fmt.Errorf("AssetType is missing or resetting is a problem %s", err.Error())
 	}
@@ -256,35 +341,63 @@ func Update(args ...interface{}) (interface{}, error) {
 		return nil, fmt.Errorf("Error in updating: Asset Id %s marshal error %s", id, errPut.Error())
 	}
 
+	if transition != nil {
+		if err := l.recordTransition(id, obj, from, to); err != nil {
+			return nil, err
+		}
+	}
+
 	fmt.Println("Success in initiating Transaction Asset", obj)
 	return obj, nil
 }
 
 // Delete deletes the asset from the ledger
-func Delete(Id string) (interface{}, error) {
-	stub := util.Stub
+func (l *Ledger) Delete(Id string) (interface{}, error) {
+	stub := l.ctx.Stub
 
 	assetAsBytes, _ := stub.GetState(Id)
 	if assetAsBytes == nil {
 		return nil, fmt.Errorf("Error in deleting: could not find asset with Id %s", Id)
 	}
 
-	errPut := stub.DelState(Id)
-	if errPut != nil {
-		return nil, fmt.Errorf("Error in deleting: failed to delete asset with Id %s error %s", Id, errPut.Error())
-	}
-
-	var result interface{}
+	var result map[string]interface{}
 	unmarshalError := json.Unmarshal(assetAsBytes, &result)
 	if unmarshalError != nil {
 		return nil, fmt.Errorf("Error in deleting: marshalling error %s", unmarshalError.Error())
 	}
+	if isSoftDeleted(result) {
+		return nil, fmt.Errorf("Error in deleting: could not find asset with Id %s", Id)
+	}
+
+	config, err := l.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config.Features.SoftDelete {
+		// keep the record on the ledger, flagged so that it is no longer returned
+		result[SoftDeletedField] = true
+		deletedAsBytes, errMarshal := json.Marshal(result)
+		if errMarshal != nil {
+			return nil, fmt.Errorf("Error in deleting: Asset Id %s marshal error %s", Id, errMarshal.Error())
+		}
+		errPut := stub.PutState(Id, deletedAsBytes)
+		if errPut != nil {
+			return nil, fmt.Errorf("Error in deleting: failed to delete asset with Id %s error %s", Id, errPut.Error())
+		}
+		delete(result, SoftDeletedField)
+		return result, nil
+	}
+
+	errPut := stub.DelState(Id)
+	if errPut != nil {
+		return nil, fmt.Errorf("Error in deleting: failed to delete asset with Id %s error %s", Id, errPut.Error())
+	}
 	return result, nil
 }
 
 // Query runs the given transaction on the peer
-func Query(queryString string) ([]interface{}, error) {
-	stub := util.Stub
+func (l *Ledger) Query(queryString string) ([]interface{}, error) {
+	stub := l.ctx.Stub
 	fmt.Printf("Query: queryString:\n%s\n", queryString)
 
 	resultsIterator, err := stub.GetQueryResult(queryString)
@@ -320,8 +433,8 @@ func Query(queryString string) ([]interface{}, error) {
 }
 
 // GetByRange gets all the assets with key between the provided range
-func GetByRange(startKey string, endKey string, asset ...interface{}) ([]map[string]interface{}, error) {
-	stub := util.Stub
+func (l *Ledger) GetByRange(startKey string, endKey string, asset ...interface{}) ([]map[string]interface{}, error) {
+	stub := l.ctx.Stub
 	if len(asset) > 0 {
 		resultsIterator, err := stub.GetStateByRange(startKey, endKey)
 
@@ -329,8 +442,10 @@ func GetByRange(startKey string, endKey string, asset ...interface{}) ([]map[str
 		// fmt.Println("GetSupplierByRange", reflect.TypeOf(asset[0]).Kind())
 		// fmt.Println("GetSupplierByRange", reflect.TypeOf(asset[0]).Elem().Elem())
 
-		inputAssetTypeString := reflect.TypeOf(asset[0]).Elem().Elem().String()
-		inputAssetType := strings.Split(inputAssetTypeString, ".")[1]
+		inputAssetType, typeErr := assetTypeOf(reflect.TypeOf(asset[0]))
+		if typeErr != nil {
+			return nil, fmt.Errorf("Error in getting by range: %s", typeErr.Error())
+		}
 		//fmt.Println("GetSupplierByRange", reflect.TypeOf(asset[0]))
 		//fmt.Println("GetSupplierByRange", reflect.TypeOf(asset[0]).Kind())
 
@@ -347,6 +462,9 @@ func GetByRange(startKey string, endKey string, asset ...interface{}) ([]map[str
 			if err != nil {
 				return nil, fmt.Errorf("Error in getting by range: iteration error %s", err.Error())
 			}
+			if isCompositeKey(queryResponse.Key) {
+				continue
+			}
 			// Add a comma before array members, suppress it for the first array member
 			if bArrayMemberAlreadyWritten == true {
 				buffer.WriteString(",")
@@ -375,14 +493,15 @@ func GetByRange(startKey string, endKey string, asset ...interface{}) ([]map[str
 			entry := result[i].(map[string]interface{})
 			//fmt.Println(entry)
 			value := entry["Record"]
-			mapAsset := value.(map[string]interface{})
-			assetTypeString := mapAsset["AssetType"].(string)
-			assetTypeSplit := strings.Split(assetTypeString, ".")
-
-			chaincodeName := assetTypeSplit[0]
-			// fmt.Println("Printing", chaincodeName, util.ChaincodeName)
-			// fmt.Println("Printing", assetTypeSplit[1], inputAssetType)
-			if len(assetTypeSplit) > 1 && assetTypeSplit[1] == inputAssetType && chaincodeName == util.ChaincodeName {
+			mapAsset, ok := value.(map[string]interface{})
+			if !ok || isSoftDeleted(mapAsset) {
+				continue
+			}
+			assetTypeString, ok := mapAsset["AssetType"].(string)
+			if !ok {
+				continue
+			}
+			if assetTypeString == inputAssetType {
 				// fmt.Println("here")
 				// assetType := assetTypeSplit[1]
 				// fmt.Println("inputAssetType", inputAssetType, "AssetType", assetType)
@@ -424,6 +543,9 @@ func GetByRange(startKey string, endKey string, asset ...interface{}) ([]map[str
 		if err != nil {
 			return nil, fmt.Errorf("Error in getting by range: iteration error %s", err.Error())
 		}
+		if isCompositeKey(queryResponse.Key) {
+			continue
+		}
 		// Add a comma before array members, suppress it for the first array member
 		if bArrayMemberAlreadyWritten == true {
 			buffer.WriteString(",")
@@ -449,9 +571,9 @@ func GetByRange(startKey string, endKey string, asset ...interface{}) ([]map[str
 }
 
 // GetHistoryByID gets the history of an asset from the ledger
-func GetHistoryByID(Id string) ([]interface{}, error) {
+func (l *Ledger) GetHistoryByID(Id string) ([]interface{}, error) {
 	recordKey := Id
-	stub := util.Stub
+	stub := l.ctx.Stub
 	// fmt.Printf("- start getHistoryForRecord: %s\n", recordKey)
 
 	resultsIterator, err := stub.GetHistoryForKey(recordKey)
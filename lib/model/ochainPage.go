@@ -0,0 +1,52 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// GetPageByRange reads into the slice assets points to up to pageSize assets of its type, with keys from startKey
+// up to endKey. It stops reading at the first asset after the page and returns its key, the start of the next page,
+// or "" after the last page, so that a page costs no more reads than its records and the keys of other assets.
+func (l *Ledger) GetPageByRange(startKey string, endKey string, pageSize int, assets interface{}) (string, error) {
+	assetType, err := assetTypeOf(reflect.TypeOf(assets))
+	if err != nil {
+		return "", fmt.Errorf("Error in getting page by range: %s", err.Error())
+	}
+	resultsIterator, err := l.ctx.Stub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return "", fmt.Errorf("Error in getting page by range: %s", err.Error())
+	}
+	defer resultsIterator.Close()
+	records := make([]string, 0, pageSize)
+	nextKey := ""
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return "", fmt.Errorf("Error in getting page by range: iteration error %s", err.Error())
+		}
+		if isCompositeKey(queryResponse.Key) {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(queryResponse.Value, &record); err != nil || isSoftDeleted(record) || record["AssetType"] != assetType {
+			continue
+		}
+		if len(records) == pageSize {
+			nextKey = queryResponse.Key
+			break
+		}
+		records = append(records, string(queryResponse.Value))
+	}
+	if err := json.Unmarshal([]byte("["+strings.Join(records, ",")+"]"), assets); err != nil {
+		return "", fmt.Errorf("Error in getting page by range: unmarshalling error %s", err.Error())
+	}
+	return nextKey, nil
+}
@@ -0,0 +1,256 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"example.com/fffffefe/lib/util"
+)
+
+const (
+	// DefaultQueryLimit is the number of records returned when a query gives no limit
+	DefaultQueryLimit = 100
+	// MaxQueryLimit is the largest number of records a query can return
+	MaxQueryLimit = 1000
+)
+
+// queryOperators maps the operators of QueryFilter to CouchDB Mango operators
+var queryOperators = map[string]string{
+	"eq":    "$eq",
+	"ne":    "$ne",
+	"gt":    "$gt",
+	"gte":   "$gte",
+	"lt":    "$lt",
+	"lte":   "$lte",
+	"in":    "$in",
+	"regex": "$regex",
+}
+
+// QueryFilter compares a field of the asset with a value. Op is one of eq, ne, gt, gte, lt, lte,
+// in (Value is an array) or regex (Value is a regular expression).
+type QueryFilter struct {
+	Field string      `json:"Field"`
+	Op    string      `json:"Op"`
+	Value interface{} `json:"Value"`
+}
+
+// QuerySort orders the results on a field in a couchIndex
+type QuerySort struct {
+	Field      string `json:"Field"`
+	Descending bool   `json:"Descending"`
+}
+
+// QuerySpec is a structured query over the assets of one type. Field names may be dotted paths into embedded assets.
+type QuerySpec struct {
+	AssetType string        `json:"AssetType"`
+	Filters   []QueryFilter `json:"Filters"`
+	Sort      []QuerySort   `json:"Sort"`
+	// Fields restricts the fields returned. All fields are returned when it is empty.
+	Fields []string `json:"Fields"`
+	Limit  int      `json:"Limit"`
+}
+
+// queryField returns the struct field of assetType at the dotted json path
+func queryField(assetType reflect.Type, path string) (reflect.StructField, error) {
+	var field reflect.StructField
+	current := assetType
+	for _, name := range strings.Split(path, ".") {
+		for current.Kind() == reflect.Ptr {
+			current = current.Elem()
+		}
+		if current.Kind() != reflect.Struct {
+			return field, fmt.Errorf("Error in query: %s has no field %s", assetType.Name(), path)
+		}
+		found := false
+		for i := 0; i < current.NumField(); i++ {
+			candidate := current.Field(i)
+			jsonName := strings.Split(candidate.Tag.Get("json"), ",")[0]
+			if jsonName != "" && jsonName != "-" && jsonName == name {
+				field, found = candidate, true
+				break
+			}
+		}
+		if !found {
+			return field, fmt.Errorf("Error in query: %s has no field %s", assetType.Name(), path)
+		}
+		current = field.Type
+	}
+	return field, nil
+}
+
+// CompileQuery checks spec against the fields of the asset prototype and compiles it into a CouchDB Mango query.
+// Only fields of the asset can be used, and sorting is only allowed where a couchIndex tagged index can serve it.
+func CompileQuery(spec QuerySpec, prototype interface{}) (string, error) {
+	assetType := assetStructType(prototype)
+	if spec.AssetType != assetType.Name() {
+		return "", fmt.Errorf("Error in query: AssetType %s does not match %s", spec.AssetType, assetType.Name())
+	}
+	selector := map[string]interface{}{"AssetType": util.ChaincodeName + "." + assetType.Name()}
+	conditions := make(map[string]map[string]interface{})
+	for _, filter := range spec.Filters {
+		if _, err := queryField(assetType, filter.Field); err != nil {
+			return "", err
+		}
+		operator, ok := queryOperators[filter.Op]
+		if !ok {
+			return "", fmt.Errorf("Error in query: unsupported operator %s on field %s", filter.Op, filter.Field)
+		}
+		switch filter.Op {
+		case "in":
+			if reflect.ValueOf(filter.Value).Kind() != reflect.Slice {
+				return "", fmt.Errorf("Error in query: operator in on field %s needs an array value", filter.Field)
+			}
+		case "regex":
+			pattern, ok := filter.Value.(string)
+			if !ok {
+				return "", fmt.Errorf("Error in query: operator regex on field %s needs a string value", filter.Field)
+			}
+			if _, err := regexp.Compile(pattern); err != nil {
+				return "", fmt.Errorf("Error in query: invalid regex on field %s: %s", filter.Field, err.Error())
+			}
+		}
+		if filter.Field == "AssetType" {
+			return "", fmt.Errorf("Error in query: AssetType cannot be filtered, it is set by the query")
+		}
+		if conditions[filter.Field] == nil {
+			conditions[filter.Field] = make(map[string]interface{})
+		}
+		if _, duplicate := conditions[filter.Field][operator]; duplicate {
+			return "", fmt.Errorf("Error in query: operator %s given twice on field %s", filter.Op, filter.Field)
+		}
+		conditions[filter.Field][operator] = filter.Value
+	}
+
+	var sort []map[string]string
+	var sortFields []string
+	for _, order := range spec.Sort {
+		if _, err := queryField(assetType, order.Field); err != nil {
+			return "", err
+		}
+		if order.Descending != spec.Sort[0].Descending {
+			return "", fmt.Errorf("Error in query: all sort fields must have the same direction")
+		}
+		sortFields = append(sortFields, order.Field)
+		direction := "asc"
+		if order.Descending {
+			direction = "desc"
+		}
+		sort = append(sort, map[string]string{order.Field: direction})
+		// CouchDB only uses an index for sorting when its fields appear in the selector
+		if conditions[order.Field] == nil {
+			conditions[order.Field] = map[string]interface{}{"$gt": nil}
+		}
+	}
+	if len(sortFields) > 0 {
+		equalities := make(map[string]bool)
+		for field, condition := range conditions {
+			_, equalities[field] = condition["$eq"]
+		}
+		if err := checkSortIndexed(assetType, sortFields, equalities); err != nil {
+			return "", err
+		}
+	}
+	for field, condition := range conditions {
+		selector[field] = condition
+	}
+	// soft deleted records are excluded by CouchDB, so that projections and limits only see live assets
+	selector[SoftDeletedField] = map[string]interface{}{"$ne": true}
+
+	for _, field := range spec.Fields {
+		if _, err := queryField(assetType, field); err != nil {
+			return "", err
+		}
+	}
+	limit := spec.Limit
+	if limit == 0 {
+		limit = DefaultQueryLimit
+	}
+	if limit < 0 || limit > MaxQueryLimit {
+		return "", fmt.Errorf("Error in query: limit must be between 1 and %d, given %d", MaxQueryLimit, spec.Limit)
+	}
+
+	query := map[string]interface{}{"selector": selector, "limit": limit}
+	if len(sort) > 0 {
+		query["sort"] = sort
+	}
+	if len(spec.Fields) > 0 {
+		query["fields"] = spec.Fields
+	}
+	queryAsBytes, err := json.Marshal(query)
+	if err != nil {
+		return "", fmt.Errorf("Error in query: marshal error %s", err.Error())
+	}
+	return string(queryAsBytes), nil
+}
+
+// QueryAssets runs a structured query over the assets of the prototype's type. Soft deleted records are excluded
+// by the compiled selector.
+func (l *Ledger) QueryAssets(spec QuerySpec, prototype interface{}) ([]interface{}, error) {
+	query, err := CompileQuery(spec, prototype)
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := l.ctx.Stub.GetQueryResult(query)
+	if err != nil {
+		return nil, fmt.Errorf("Error in query: %s", err.Error())
+	}
+	defer resultsIterator.Close()
+	limit := spec.Limit
+	if limit == 0 {
+		limit = DefaultQueryLimit
+	}
+	results := []interface{}{}
+	for resultsIterator.HasNext() && len(results) < limit {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("Error in query: iteration error %s", err.Error())
+		}
+		decoder := json.NewDecoder(bytes.NewReader(queryResponse.Value))
+		decoder.UseNumber()
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("Error in query: unmarshalling error %s", err.Error())
+		}
+		results = append(results, record)
+	}
+	return results, nil
+}
+
+// CanQuery reports whether the caller may query the given asset type. Admins may query every type. Other callers
+// may query the types listed for their MSP in the QueryAccess config, or for "*" when their MSP is not listed.
+// Without QueryAccess config every type may be queried.
+func (l *Ledger) CanQuery(assetType string) (bool, error) {
+	if admin, err := l.IsAdmin(); err == nil && admin {
+		return true, nil
+	}
+	config, err := l.GetConfig()
+	if err != nil {
+		return false, err
+	}
+	if len(config.QueryAccess) == 0 {
+		return true, nil
+	}
+	callerMSP, err := l.ctx.CreatorMSPID()
+	if err != nil {
+		return false, err
+	}
+	allowed, ok := config.QueryAccess[callerMSP]
+	if !ok {
+		allowed = config.QueryAccess["*"]
+	}
+	for _, allowedType := range allowed {
+		if allowedType == assetType || allowedType == "*" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,168 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"example.com/fffffefe/lib/util"
+	"example.com/fffffefe/lib/util/decimal"
+)
+
+// ReportSpec describes an aggregate over the assets of one type. Field names may be dotted paths into
+// embedded assets, e.g. Account.License.
+type ReportSpec struct {
+	// AssetType is the asset type name, e.g. Supplier
+	AssetType string `json:"AssetType"`
+	// GroupBy lists the fields whose values form a group. Without it all matching assets form one group.
+	GroupBy []string `json:"GroupBy"`
+	// Sum lists the numeric or decimal fields to total in each group
+	Sum []string `json:"Sum"`
+	// Filter keeps only the assets whose fields equal the given values
+	Filter map[string]interface{} `json:"Filter"`
+}
+
+// ReportRow holds the aggregates of one group
+type ReportRow struct {
+	Group  map[string]interface{}     `json:"Group"`
+	Count  int                        `json:"Count"`
+	Totals map[string]decimal.Decimal `json:"Totals"`
+}
+
+// ForEachAsset calls fn with every live asset of the given type, one at a time. It uses a rich query on
+// the AssetType field where the state database supports it and falls back to a range scan otherwise, e.g.
+// on LevelDB. Records are decoded with json.Number so that numbers keep their exact value.
+func (l *Ledger) ForEachAsset(assetType string, fn func(record map[string]interface{}) error) error {
+	stub := l.ctx.Stub
+	qualifiedType := util.ChaincodeName + "." + assetType
+	selector, _ := json.Marshal(map[string]interface{}{"selector": map[string]string{"AssetType": qualifiedType}})
+	resultsIterator, err := stub.GetQueryResult(string(selector))
+	if err != nil {
+		resultsIterator, err = stub.GetStateByRange("", "")
+		if err != nil {
+			return fmt.Errorf("Error in iterating assets: %s", err.Error())
+		}
+	}
+	defer resultsIterator.Close()
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return fmt.Errorf("Error in iterating assets: iteration error %s", err.Error())
+		}
+		if isCompositeKey(queryResponse.Key) {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(queryResponse.Value))
+		decoder.UseNumber()
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			continue
+		}
+		if recordType, _ := record["AssetType"].(string); recordType != qualifiedType || isSoftDeleted(record) {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldValue returns the value at the dotted path in the record
+func fieldValue(record map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = record
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// sameValue compares values through their JSON encoding, so that e.g. the number 5 from a filter equals json.Number("5")
+func sameValue(a interface{}, b interface{}) bool {
+	aAsBytes, errA := json.Marshal(a)
+	bAsBytes, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aAsBytes, bAsBytes)
+}
+
+func toDecimal(value interface{}) (decimal.Decimal, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return decimal.Parse(v.String())
+	case string:
+		return decimal.Parse(v)
+	case nil:
+		return decimal.Decimal{}, nil
+	}
+	return decimal.Decimal{}, fmt.Errorf("%v is not a number", value)
+}
+
+// Report computes the aggregates described by spec, keeping only one running total per group in memory.
+// Rows are ordered by their group values. Reports are queries, so the caller may only report on the asset types
+// its QueryAccess allows.
+func (l *Ledger) Report(spec ReportSpec) ([]ReportRow, error) {
+	if spec.AssetType == "" {
+		return nil, fmt.Errorf("Error in report: AssetType is mandatory")
+	}
+	allowed, err := l.CanQuery(spec.AssetType)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, fmt.Errorf("Access denied: caller may not report on %s", spec.AssetType)
+	}
+	rows := make(map[string]*ReportRow)
+	err = l.ForEachAsset(spec.AssetType, func(record map[string]interface{}) error {
+		for field, expected := range spec.Filter {
+			value, _ := fieldValue(record, field)
+			if !sameValue(value, expected) {
+				return nil
+			}
+		}
+		group := make(map[string]interface{})
+		for _, field := range spec.GroupBy {
+			group[field], _ = fieldValue(record, field)
+		}
+		keyAsBytes, _ := json.Marshal(group)
+		row, ok := rows[string(keyAsBytes)]
+		if !ok {
+			row = &ReportRow{Group: group, Totals: make(map[string]decimal.Decimal)}
+			rows[string(keyAsBytes)] = row
+		}
+		row.Count++
+		for _, field := range spec.Sum {
+			value, _ := fieldValue(record, field)
+			amount, err := toDecimal(value)
+			if err != nil {
+				return fmt.Errorf("Error in report: field %s of %s: %s", field, spec.AssetType, err.Error())
+			}
+			row.Totals[field] = row.Totals[field].Add(amount)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := []ReportRow{}
+	for _, key := range keys {
+		result = append(result, *rows[key])
+	}
+	return result, nil
+}
@@ -0,0 +1,52 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package model
+
+import (
+	"fmt"
+	"reflect"
+
+	"example.com/fffffefe/lib/util"
+	"example.com/fffffefe/lib/util/validators"
+	"github.com/creasty/defaults"
+)
+
+// CheckAssetType reports the mistakes in the definition of an asset type which would otherwise only surface when
+// a transaction saves or validates one: missing or unusable id fields, an AssetType not named
+// <ChaincodeName>.<Type>, defaults which cannot be set and validate tags naming unknown validators.
+func CheckAssetType(prototype interface{}) []string {
+	assetType := reflect.TypeOf(prototype)
+	for assetType.Kind() == reflect.Ptr {
+		assetType = assetType.Elem()
+	}
+	if assetType.Kind() != reflect.Struct {
+		return []string{fmt.Sprintf("%s: asset type is not a struct", assetType)}
+	}
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("%s: %s", assetType.Name(), fmt.Sprintf(format, args...)))
+	}
+
+	if len(idFields(assetType)) == 0 {
+		report("no field is tagged id")
+	}
+	for _, problem := range checkIdFields(assetType) {
+		report("%s", problem)
+	}
+	expected := util.ChaincodeName + "." + assetType.Name()
+	if field, ok := assetType.FieldByName("AssetType"); !ok {
+		report("no AssetType field")
+	} else if final := field.Tag.Get("final"); final != expected {
+		report("AssetType is final %q, expected %q", final, expected)
+	}
+	if err := defaults.Set(reflect.New(assetType).Interface()); err != nil {
+		report("defaults cannot be set: %s", err.Error())
+	}
+	for _, problem := range validators.CheckTags(assetType) {
+		problems = append(problems, problem)
+	}
+	return problems
+}
@@ -0,0 +1,224 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/fffffefe/lib/util"
+	"example.com/fffffefe/lib/util/date"
+)
+
+// transitionIndex keys the audit trail by asset id, transaction id and the sequence of the transition within the
+// transaction. Records written before the sequence was added have none.
+const transitionIndex = "Transition~id~txId"
+
+// transitionSequenceValue is the context value numbering the transitions recorded within one transaction
+const transitionSequenceValue = "model.transitionSequence"
+
+// Guard decides whether a transition may happen. It receives the context of the transaction, the asset as stored
+// in the ledger and the updated asset, both as pointers to the asset struct. Guards are evaluated more than once
+// and must not have side effects.
+type Guard func(ctx *util.TxContext, current interface{}, updated interface{}) error
+
+// Transition is an allowed change of the status field of an asset
+type Transition struct {
+	From  string
+	To    string
+	Guard Guard
+}
+
+// StateMachine describes the lifecycle of the status field of an asset
+type StateMachine struct {
+	// Field is the name of the string field holding the status
+	Field string
+	// Initial lists the statuses an asset can be created with. The first one is used when the status is empty.
+	Initial     []string
+	Transitions []Transition
+}
+
+// StatefulAsset is implemented by assets whose status field follows a state machine.
+// Save and Update reject assets which do not respect it.
+type StatefulAsset interface {
+	StateMachine() *StateMachine
+}
+
+// TransitionRecord is the audit trail entry written for every status change
+type TransitionRecord struct {
+	AssetId   string    `json:"AssetId"`
+	AssetType string    `json:"AssetType"`
+	Field     string    `json:"Field"`
+	From      string    `json:"From"`
+	To        string    `json:"To"`
+	TxId      string    `json:"TxId"`
+	Sequence  int       `json:"Sequence"`
+	Creator   string    `json:"Creator"`
+	Timestamp date.Date `json:"Timestamp"`
+}
+
+func statusField(obj interface{}, machine *StateMachine) (reflect.Value, error) {
+	field := reflect.ValueOf(obj).Elem().FieldByName(machine.Field)
+	if !field.IsValid() || field.Kind() != reflect.String {
+		return reflect.Value{}, fmt.Errorf("State machine error: %T has no string field %s", obj, machine.Field)
+	}
+	return field, nil
+}
+
+// checkInitialState sets an empty status to the first initial status and rejects any other non initial status
+func checkInitialState(obj interface{}) error {
+	stateful, ok := obj.(StatefulAsset)
+	if !ok {
+		return nil
+	}
+	machine := stateful.StateMachine()
+	field, err := statusField(obj, machine)
+	if err != nil {
+		return err
+	}
+	if len(machine.Initial) == 0 {
+		return nil
+	}
+	if field.String() == "" {
+		field.SetString(machine.Initial[0])
+		return nil
+	}
+	for _, initial := range machine.Initial {
+		if field.String() == initial {
+			return nil
+		}
+	}
+	return fmt.Errorf("Error in saving: %s cannot start in %s %s, allowed initial values are %s", reflect.TypeOf(obj).Elem().Name(), machine.Field, field.String(), strings.Join(machine.Initial, ", "))
+}
+
+// findTransition returns the transition of the asset's status from its ledger value, or nil if the status is unchanged
+func (l *Ledger) findTransition(obj interface{}, existingAsBytes []byte) (*Transition, string, string, error) {
+	stateful, ok := obj.(StatefulAsset)
+	if !ok {
+		return nil, "", "", nil
+	}
+	machine := stateful.StateMachine()
+	field, err := statusField(obj, machine)
+	if err != nil {
+		return nil, "", "", err
+	}
+	current := reflect.New(reflect.TypeOf(obj).Elem()).Interface()
+	if err := json.Unmarshal(existingAsBytes, current); err != nil {
+		return nil, "", "", fmt.Errorf("Error in updating: unmarshalling error %s", err.Error())
+	}
+	currentField, _ := statusField(current, machine)
+	from, to := currentField.String(), field.String()
+	if from == to {
+		return nil, from, to, nil
+	}
+	// records written before the state machine was declared have no status and may take any initial value
+	if from == "" {
+		for _, initial := range machine.Initial {
+			if to == initial {
+				return &Transition{From: from, To: to}, from, to, nil
+			}
+		}
+	}
+	for i := range machine.Transitions {
+		transition := machine.Transitions[i]
+		if transition.From != from || transition.To != to {
+			continue
+		}
+		if transition.Guard != nil {
+			if err := transition.Guard(l.ctx, current, obj); err != nil {
+				return nil, from, to, fmt.Errorf("Error in updating: %s cannot change from %s to %s: %s", machine.Field, from, to, err.Error())
+			}
+		}
+		return &transition, from, to, nil
+	}
+	return nil, from, to, fmt.Errorf("Error in updating: illegal transition of %s from %s to %s", machine.Field, from, to)
+}
+
+// CheckTransition verifies that the status change of the asset, compared to the ledger, is allowed by its state machine
+func (l *Ledger) CheckTransition(obj interface{}) error {
+	id, err := getID(obj)
+	if err != nil {
+		return err
+	}
+	existingAsBytes, _ := l.ctx.Stub.GetState(id)
+	if existingAsBytes == nil {
+		return fmt.Errorf("Error in updating: Unable to get the asset from ledger with ID %s", id)
+	}
+	_, _, _, err = l.findTransition(obj, existingAsBytes)
+	return err
+}
+
+func (l *Ledger) recordTransition(id string, obj interface{}, from string, to string) error {
+	stub := l.ctx.Stub
+	creator, _ := l.ctx.CreatorMSPID()
+	timestamp, err := l.GetTransactionTimestamp()
+	if err != nil {
+		return fmt.Errorf("Error in recording transition: %s", err.Error())
+	}
+	record := TransitionRecord{
+		AssetId:   id,
+		AssetType: reflect.TypeOf(obj).Elem().Name(),
+		Field:     obj.(StatefulAsset).StateMachine().Field,
+		From:      from,
+		To:        to,
+		TxId:      stub.GetTxID(),
+		Sequence:  l.nextTransitionSequence(),
+		Creator:   creator,
+		Timestamp: date.Date{Time: time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC()},
+	}
+	recordAsBytes, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("Error in recording transition: marshal error %s", err.Error())
+	}
+	key, err := stub.CreateCompositeKey(transitionIndex, []string{id, record.TxId, fmt.Sprintf("%06d", record.Sequence)})
+	if err != nil {
+		return fmt.Errorf("Error in recording transition: %s", err.Error())
+	}
+	if err := stub.PutState(key, recordAsBytes); err != nil {
+		return fmt.Errorf("Error in recording transition: transaction error %s", err.Error())
+	}
+	return nil
+}
+
+// nextTransitionSequence numbers the transitions of a transaction, so that an asset changing status twice in one
+// transaction keeps both records
+func (l *Ledger) nextTransitionSequence() int {
+	next, _ := l.ctx.Value(transitionSequenceValue).(int)
+	l.ctx.SetValue(transitionSequenceValue, next+1)
+	return next
+}
+
+// GetTransitionsByID returns the audit trail of status changes of an asset, oldest first
+func (l *Ledger) GetTransitionsByID(Id string) ([]TransitionRecord, error) {
+	resultsIterator, err := l.ctx.Stub.GetStateByPartialCompositeKey(transitionIndex, []string{Id})
+	if err != nil {
+		return nil, fmt.Errorf("Error in getting transitions: %s", err.Error())
+	}
+	defer resultsIterator.Close()
+	records := []TransitionRecord{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("Error in getting transitions: iteration error %s", err.Error())
+		}
+		var record TransitionRecord
+		if err := json.Unmarshal(queryResult.Value, &record); err != nil {
+			return nil, fmt.Errorf("Error in getting transitions: unmarshalling error %s", err.Error())
+		}
+		records = append(records, record)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].Timestamp.Equal(records[j].Timestamp.Time) {
+			return records[i].Timestamp.Before(records[j].Timestamp)
+		}
+		return records[i].Sequence < records[j].Sequence
+	})
+	return records, nil
+}
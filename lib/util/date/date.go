@@ -19,6 +19,9 @@ const (
 //Date object. This holds time
 type Date struct {
 	time.Time
+	// contract is the property the contract api, which describes structs by their fields, describes dates by.
+	// Dates are read and written as YYYY-MM-DD strings.
+	contract string `metadata:"date"`
 }
 
 func (d Date) String() (string, error) {
@@ -0,0 +1,238 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package decimal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxScale is the largest number of fractional digits a Decimal can carry
+	MaxScale = 18
+)
+
+var decimalPattern = regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)
+
+// Decimal is a fixed point number, the unscaled integer value divided by 10 to the power of scale.
+// Unlike float64 it represents amounts like 0.1 exactly, and the same value always encodes to the same JSON,
+// which keeps results identical on every endorsing peer. The zero value is 0.
+// Decimals are immutable, all operations return a new Decimal.
+type Decimal struct {
+	unscaled *big.Int
+	// The contract api describes structs by their exported fields and the unexported ones named by a metadata tag,
+	// and refuses to start with a struct it has no field to describe by, hence the tag. That description is not
+	// used to read or write decimals: they are written as JSON strings and read from JSON strings or numbers, as
+	// the validators schema builder describes them.
+	scale int32 `metadata:"scale"`
+}
+
+// New returns unscaled * 10^-scale
+func New(unscaled int64, scale int32) Decimal {
+	if scale < 0 || scale > MaxScale {
+		panic(fmt.Sprintf("Decimal Error: scale must be between 0 and %d, given %d", MaxScale, scale))
+	}
+	return Decimal{unscaled: big.NewInt(unscaled), scale: scale}
+}
+
+// NewFromInt returns the integer i as a Decimal of scale 0
+func NewFromInt(i int64) Decimal {
+	return New(i, 0)
+}
+
+// Parse reads a decimal written as digits with an optional sign and fractional part, e.g. -12.50.
+// The scale of the result is the number of fractional digits given. Exponents are not accepted.
+func Parse(input string) (Decimal, error) {
+	if !decimalPattern.MatchString(input) {
+		return Decimal{}, fmt.Errorf("Decimal Parse Error: %s is not a decimal number", input)
+	}
+	digits := input
+	scale := 0
+	if point := strings.IndexByte(input, '.'); point >= 0 {
+		scale = len(input) - point - 1
+		digits = input[:point] + input[point+1:]
+	}
+	if scale > MaxScale {
+		return Decimal{}, fmt.Errorf("Decimal Parse Error: %s has more than %d fractional digits", input, MaxScale)
+	}
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("Decimal Parse Error: %s is not a decimal number", input)
+	}
+	return Decimal{unscaled: unscaled, scale: int32(scale)}, nil
+}
+
+// MustParse is Parse panicking on invalid input, for constants
+func MustParse(input string) Decimal {
+	d, err := Parse(input)
+	if err != nil {
+		panic(err.Error())
+	}
+	return d
+}
+
+func (d Decimal) value() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Scale returns the number of fractional digits of d
+func (d Decimal) Scale() int32 {
+	return d.scale
+}
+
+// String formats d with exactly Scale fractional digits
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.value()).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		point := len(digits) - int(d.scale)
+		digits = digits[:point] + "." + digits[point:]
+	}
+	if d.value().Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Rescale returns d with the given number of fractional digits. Digits dropped are rounded half away from zero.
+func (d Decimal) Rescale(scale int32) Decimal {
+	if scale < 0 || scale > MaxScale {
+		panic(fmt.Sprintf("Decimal Error: scale must be between 0 and %d, given %d", MaxScale, scale))
+	}
+	if scale >= d.scale {
+		return Decimal{unscaled: new(big.Int).Mul(d.value(), pow10(scale-d.scale)), scale: scale}
+	}
+	return Decimal{unscaled: roundQuotient(d.value(), pow10(d.scale-scale)), scale: scale}
+}
+
+// roundQuotient returns numerator / denominator rounded half away from zero, for a positive denominator
+func roundQuotient(numerator *big.Int, denominator *big.Int) *big.Int {
+	quotient, remainder := new(big.Int).QuoRem(numerator, denominator, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(denominator) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(numerator.Sign())))
+	}
+	return quotient
+}
+
+// align returns the unscaled values of d and other at the larger of their scales
+func (d Decimal) align(other Decimal) (*big.Int, *big.Int, int32) {
+	scale := d.scale
+	if other.scale > scale {
+		scale = other.scale
+	}
+	return d.Rescale(scale).unscaled, other.Rescale(scale).unscaled, scale
+}
+
+// Add returns d + other, at the larger of their scales
+func (d Decimal) Add(other Decimal) Decimal {
+	a, b, scale := d.align(other)
+	return Decimal{unscaled: a.Add(a, b), scale: scale}
+}
+
+// Sub returns d - other, at the larger of their scales
+func (d Decimal) Sub(other Decimal) Decimal {
+	a, b, scale := d.align(other)
+	return Decimal{unscaled: a.Sub(a, b), scale: scale}
+}
+
+// Mul returns d * other, at the sum of their scales, or MaxScale rounded if the sum is larger
+func (d Decimal) Mul(other Decimal) Decimal {
+	product := Decimal{unscaled: new(big.Int).Mul(d.value(), other.value()), scale: d.scale + other.scale}
+	if product.scale > MaxScale {
+		return Decimal{unscaled: roundQuotient(product.unscaled, pow10(product.scale-MaxScale)), scale: MaxScale}
+	}
+	return product
+}
+
+// MulInt returns d * i, at the scale of d
+func (d Decimal) MulInt(i int64) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.value(), big.NewInt(i)), scale: d.scale}
+}
+
+// Div returns d / other rounded half away from zero to the given scale
+func (d Decimal) Div(other Decimal, scale int32) (Decimal, error) {
+	if other.Sign() == 0 {
+		return Decimal{}, fmt.Errorf("Decimal Error: division by zero")
+	}
+	if scale < 0 || scale > MaxScale {
+		return Decimal{}, fmt.Errorf("Decimal Error: scale must be between 0 and %d, given %d", MaxScale, scale)
+	}
+	// d / other = (d.unscaled * 10^(scale - d.scale + other.scale)) / other.unscaled at the requested scale
+	numerator := new(big.Int).Set(d.value())
+	denominator := new(big.Int).Set(other.value())
+	if shift := scale - d.scale + other.scale; shift >= 0 {
+		numerator.Mul(numerator, pow10(shift))
+	} else {
+		denominator.Mul(denominator, pow10(-shift))
+	}
+	if denominator.Sign() < 0 {
+		numerator.Neg(numerator)
+		denominator.Neg(denominator)
+	}
+	return Decimal{unscaled: roundQuotient(numerator, denominator), scale: scale}, nil
+}
+
+// Neg returns -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(d.value()), scale: d.scale}
+}
+
+// Sign returns -1, 0 or 1 as d is negative, zero or positive
+func (d Decimal) Sign() int {
+	return d.value().Sign()
+}
+
+// IsZero reports whether d is 0
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Cmp returns -1, 0 or 1 as d is less than, equal to or greater than other, regardless of their scales
+func (d Decimal) Cmp(other Decimal) int {
+	a, b, _ := d.align(other)
+	return a.Cmp(b)
+}
+
+// Equal reports whether d and other have the same value, e.g. 1.5 and 1.50
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+// MarshalJSON is the implementation of Marshaller interface for Decimal. Decimals are written as JSON strings
+// so that clients parsing numbers as floating point do not lose precision.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON is the implementation of Unmarshaller interface for Decimal. Both JSON strings and numbers are accepted.
+func (d *Decimal) UnmarshalJSON(input []byte) error {
+	input = bytes.TrimSpace(input)
+	if string(input) == "null" {
+		return nil
+	}
+	if len(input) >= 2 && input[0] == '"' && input[len(input)-1] == '"' {
+		input = input[1 : len(input)-1]
+	}
+	parsed, err := Parse(string(input))
+	if err != nil {
+		return fmt.Errorf("Decimal Unmarshal Error: %s", err.Error())
+	}
+	*d = parsed
+	return nil
+}
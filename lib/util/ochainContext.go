@@ -0,0 +1,175 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package util
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// TxContext carries everything a transaction works with: the stub, the submitter's identity, the transaction
+// time, a logger and per-transaction values such as the cached configuration. A new context is created for every
+// transaction, so nothing leaks between transactions even when the shim dispatches them concurrently.
+//
+// TxContext also implements the settable transaction context of fabric-contract-api-go, so that the controller
+// methods taking it can be exposed as a contract without change.
+type TxContext struct {
+	Stub   shim.ChaincodeStubInterface
+	Logger *log.Logger
+	// Config is the chaincode configuration, a *model.Config read once per transaction by model.GetConfig
+	Config   interface{}
+	values   map[string]interface{}
+	identity cid.ClientIdentity
+}
+
+// NewTxContext returns the context of the transaction run by stub
+func NewTxContext(stub shim.ChaincodeStubInterface) *TxContext {
+	ctx := new(TxContext)
+	ctx.SetStub(stub)
+	return ctx
+}
+
+// SetStub resets the context to the transaction run by stub
+func (ctx *TxContext) SetStub(stub shim.ChaincodeStubInterface) {
+	prefix := fmt.Sprintf("[%s] ", ChaincodeName)
+	if stub != nil {
+		prefix = fmt.Sprintf("[%s %s] ", ChaincodeName, shortTxID(stub.GetTxID()))
+	}
+	ctx.Stub = stub
+	ctx.Logger = log.New(os.Stdout, prefix, log.LstdFlags)
+	ctx.Config = nil
+	ctx.values = make(map[string]interface{})
+	ctx.identity = nil
+}
+
+// GetStub returns the stub of the transaction
+func (ctx *TxContext) GetStub() shim.ChaincodeStubInterface {
+	return ctx.Stub
+}
+
+// SetClientIdentity sets the identity of the submitter, as parsed by the contract api
+func (ctx *TxContext) SetClientIdentity(identity cid.ClientIdentity) {
+	ctx.identity = identity
+}
+
+// GetClientIdentity returns the identity of the submitter, parsing it from the stub if it has not been set.
+// It is nil when the creator cannot be parsed.
+func (ctx *TxContext) GetClientIdentity() cid.ClientIdentity {
+	if ctx.identity == nil {
+		if identity, err := cid.New(ctx.Stub); err == nil {
+			ctx.identity = identity
+		}
+	}
+	return ctx.identity
+}
+
+func shortTxID(txID string) string {
+	if len(txID) > 8 {
+		return txID[:8]
+	}
+	return txID
+}
+
+// TxContextType is the type of the optional first parameter through which ExecuteMethod passes the context
+var TxContextType = reflect.TypeOf((*TxContext)(nil))
+
+// globalContext is the context of the transaction the global Stub belongs to, for code not yet taking a context
+var globalContext struct {
+	sync.Mutex
+	ctx  *TxContext
+	txID string
+}
+
+// CurrentContext returns the context of the transaction run by the global Stub. It lets functions which do not
+// take a context yet share the state of the methods which do. New code should take a *TxContext instead.
+func CurrentContext() *TxContext {
+	globalContext.Lock()
+	defer globalContext.Unlock()
+	txID := ""
+	if Stub != nil {
+		txID = Stub.GetTxID()
+	}
+	if globalContext.ctx == nil || globalContext.ctx.Stub != Stub || globalContext.txID != txID {
+		globalContext.ctx = NewTxContext(Stub)
+		globalContext.txID = txID
+	}
+	return globalContext.ctx
+}
+
+// SetCurrentContext makes ctx the context returned by CurrentContext, with its stub as the global Stub.
+// Entrypoints call it before running a transaction.
+func SetCurrentContext(ctx *TxContext) {
+	globalContext.Lock()
+	defer globalContext.Unlock()
+	Stub = ctx.Stub
+	globalContext.ctx = ctx
+	globalContext.txID = ctx.Stub.GetTxID()
+}
+
+// Value returns the per-transaction value stored under key, or nil
+func (ctx *TxContext) Value(key string) interface{} {
+	return ctx.values[key]
+}
+
+// SetValue stores a per-transaction value under key. A nil value removes it.
+func (ctx *TxContext) SetValue(key string, value interface{}) {
+	if value == nil {
+		delete(ctx.values, key)
+		return
+	}
+	ctx.values[key] = value
+}
+
+// Logf logs a message prefixed with the chaincode name and transaction id
+func (ctx *TxContext) Logf(format string, args ...interface{}) {
+	ctx.Logger.Printf(format, args...)
+}
+
+// TxTime returns the timestamp of the transaction, in UTC
+func (ctx *TxContext) TxTime() (time.Time, error) {
+	timestamp, err := ctx.Stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Error in getting transaction time: %s", err.Error())
+	}
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC(), nil
+}
+
+// CreatorIdentity returns the serialized identity of the submitter of the transaction
+func (ctx *TxContext) CreatorIdentity() (*msp.SerializedIdentity, error) {
+	creator, err := ctx.Stub.GetCreator()
+	if err != nil {
+		return nil, fmt.Errorf("Error in getting creator: %s", err.Error())
+	}
+	if len(creator) == 0 {
+		return nil, fmt.Errorf("Error in getting creator: transaction has no creator")
+	}
+	identity := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(creator, identity); err != nil {
+		return nil, fmt.Errorf("Error in getting creator: unmarshalling error %s", err.Error())
+	}
+	return identity, nil
+}
+
+// CreatorMSPID returns the MSP id of the organisation which submitted the transaction
+func (ctx *TxContext) CreatorMSPID() (string, error) {
+	identity, err := ctx.CreatorIdentity()
+	if err != nil {
+		return "", err
+	}
+	if identity.Mspid == "" {
+		return "", fmt.Errorf("Error in getting creator: MSP id is empty")
+	}
+	return identity.Mspid, nil
+}
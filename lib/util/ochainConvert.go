@@ -0,0 +1,241 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/fffffefe/lib/util/date"
+	"example.com/fffffefe/lib/util/decimal"
+)
+
+// ArgDecoder converts the text of a method argument into a value of argType
+type ArgDecoder func(arg string, argType reflect.Type) (reflect.Value, error)
+
+// argDecoders holds the decoders of the types which are not converted by their kind. It is the one place where
+// argument types get their conversion, extended with RegisterArgDecoder.
+var argDecoders = map[reflect.Type]ArgDecoder{
+	reflect.TypeOf(date.Date{}):       decodeDate,
+	reflect.TypeOf(decimal.Decimal{}): decodeDecimal,
+	reflect.TypeOf(time.Duration(0)):  decodeDuration,
+	reflect.TypeOf(time.Time{}):       decodeTime,
+}
+
+// RegisterArgDecoder makes ExecuteMethod convert arguments of type t with decoder, also inside slices and maps
+func RegisterArgDecoder(t reflect.Type, decoder ArgDecoder) {
+	argDecoders[t] = decoder
+}
+
+// convert converts the text of an argument into a value of argType. Registered decoders come first, then
+// pointers, which are optional, structs, which are validated assets, and the kinds.
+func convert(argKind reflect.Kind, arg string, argType reflect.Type) (reflect.Value, error) {
+	if decoder, ok := argDecoders[argType]; ok {
+		return decoder(arg, argType)
+	}
+	switch argKind {
+	case reflect.Bool:
+		val, err := strconv.ParseBool(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(val).Convert(argType), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// durations are converted by their own decoder, plain integers are never read as durations
+		val, err := strconv.ParseInt(arg, 10, argType.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(val).Convert(argType), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		val, err := strconv.ParseUint(arg, 10, argType.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(val).Convert(argType), nil
+	case reflect.Float32, reflect.Float64:
+		val, err := strconv.ParseFloat(arg, argType.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(val).Convert(argType), nil
+	case reflect.String:
+		return reflect.ValueOf(arg).Convert(argType), nil
+	case reflect.Slice:
+		return decodeSlice(arg, argType)
+	case reflect.Map:
+		return decodeMap(arg, argType)
+	case reflect.Struct:
+		ref := reflect.New(argType)
+		if err := UnmarshalAsset(arg, ref.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return ref.Elem(), nil
+	case reflect.Ptr:
+		// a pointer parameter is optional, an empty or null argument leaves it nil
+		if trimmed := strings.TrimSpace(arg); trimmed == "" || trimmed == "null" {
+			return reflect.Zero(argType), nil
+		}
+		val, err := convert(argType.Elem().Kind(), arg, argType.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		ref := reflect.New(argType.Elem())
+		ref.Elem().Set(val)
+		return ref, nil
+	}
+	return reflect.Value{}, fmt.Errorf("Argument Parsing/Validation failed: argument kind %s does not match supported kinds", argKind)
+}
+
+// ConvertArg converts the text of an argument into a value of argType as ExecuteMethod does, for entrypoints
+// which call the controller methods themselves
+func ConvertArg(arg string, argType reflect.Type) (reflect.Value, error) {
+	return convert(argType.Kind(), arg, argType)
+}
+
+// convertJSON converts an argument given as a JSON value, e.g. a named argument or an element of a slice.
+// Values which convert reads from plain text, like strings and dates, may be given as JSON strings.
+func convertJSON(raw json.RawMessage, argType reflect.Type) (reflect.Value, error) {
+	arg := string(raw)
+	var text string
+	if textArgument(argType) && json.Unmarshal(raw, &text) == nil {
+		arg = text
+	}
+	return convert(argType.Kind(), arg, argType)
+}
+
+// textArgument reports whether convert reads values of t from plain text rather than JSON
+func textArgument(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := argDecoders[t]; ok {
+		return true
+	}
+	return t.Kind() != reflect.Struct && t.Kind() != reflect.Interface
+}
+
+// decodedPerElement reports whether elements of type t need convert, rather than encoding/json, to be decoded
+func decodedPerElement(t reflect.Type) bool {
+	if _, ok := argDecoders[t]; ok {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Ptr:
+		return true
+	case reflect.Slice, reflect.Map:
+		return decodedPerElement(t.Elem())
+	}
+	return false
+}
+
+// decodeSlice converts a JSON array. Elements which are assets get their defaults and are validated one by one.
+func decodeSlice(arg string, argType reflect.Type) (reflect.Value, error) {
+	if !decodedPerElement(argType.Elem()) {
+		ref := reflect.New(argType)
+		ref.Elem().Set(reflect.MakeSlice(argType, 0, 0))
+		if err := json.Unmarshal([]byte(arg), ref.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return ref.Elem(), nil
+	}
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(arg), &elements); err != nil {
+		return reflect.Value{}, err
+	}
+	slice := reflect.MakeSlice(argType, len(elements), len(elements))
+	for i, element := range elements {
+		val, err := convertJSON(element, argType.Elem())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("Argument Parsing/Validation failed: element %d: %s", i, err.Error())
+		}
+		slice.Index(i).Set(val)
+	}
+	return slice, nil
+}
+
+// decodeMap converts a JSON object. Values which are assets get their defaults and are validated one by one.
+func decodeMap(arg string, argType reflect.Type) (reflect.Value, error) {
+	if !decodedPerElement(argType.Elem()) || argType.Key().Kind() != reflect.String {
+		ref := reflect.New(argType)
+		ref.Elem().Set(reflect.MakeMap(argType))
+		if err := json.Unmarshal([]byte(arg), ref.Interface()); err != nil {
+			return reflect.Value{}, err
+		}
+		return ref.Elem(), nil
+	}
+	var elements map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(arg), &elements); err != nil {
+		return reflect.Value{}, err
+	}
+	result := reflect.MakeMapWithSize(argType, len(elements))
+	for key, element := range elements {
+		val, err := convertJSON(element, argType.Elem())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("Argument Parsing/Validation failed: value of %s: %s", key, err.Error())
+		}
+		result.SetMapIndex(reflect.ValueOf(key).Convert(argType.Key()), val)
+	}
+	return result, nil
+}
+
+// unquote removes the quotes of a JSON string argument, so that dates and decimals may be given either way
+func unquote(arg string) string {
+	arg = strings.TrimSpace(arg)
+	var text string
+	if strings.HasPrefix(arg, `"`) && json.Unmarshal([]byte(arg), &text) == nil {
+		return text
+	}
+	return arg
+}
+
+// decodeDate reads a date as YYYY-MM-DD or RFC 3339
+func decodeDate(arg string, argType reflect.Type) (reflect.Value, error) {
+	var val date.Date
+	quoted, _ := json.Marshal(unquote(arg))
+	if err := val.UnmarshalJSON(quoted); err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(val).Convert(argType), nil
+}
+
+// decodeDecimal reads a decimal given as a plain or quoted number, e.g. 12.50 or "12.50"
+func decodeDecimal(arg string, argType reflect.Type) (reflect.Value, error) {
+	val, err := decimal.Parse(unquote(arg))
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(val).Convert(argType), nil
+}
+
+// decodeDuration reads a duration written with its unit, e.g. 90s or 1h30m. Plain numbers other than 0 are
+// rejected rather than read as nanoseconds.
+func decodeDuration(arg string, argType reflect.Type) (reflect.Value, error) {
+	val, err := time.ParseDuration(unquote(arg))
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("Argument Parsing/Validation failed: %s", err.Error())
+	}
+	return reflect.ValueOf(val).Convert(argType), nil
+}
+
+// decodeTime reads a time in RFC 3339
+func decodeTime(arg string, argType reflect.Type) (reflect.Value, error) {
+	val, err := time.Parse(time.RFC3339, unquote(arg))
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("Argument Parsing/Validation failed: %s", err.Error())
+	}
+	return reflect.ValueOf(val).Convert(argType), nil
+}
+
+// isNull reports whether a JSON argument is null
+func isNull(raw []byte) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
@@ -0,0 +1,20 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package util
+
+import (
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// GetCreatorIdentity returns the serialized identity of the submitter of the current transaction
+func GetCreatorIdentity() (*msp.SerializedIdentity, error) {
+	return CurrentContext().CreatorIdentity()
+}
+
+// GetCreatorMSPID returns the MSP id of the organisation which submitted the current transaction
+func GetCreatorMSPID() (string, error) {
+	return CurrentContext().CreatorMSPID()
+}
@@ -0,0 +1,95 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package util
+
+import (
+	"fmt"
+	"reflect"
+
+	"example.com/fffffefe/lib/util/validators"
+)
+
+// MetadataSchemaPrefix is where the asset schemas are found in the contract metadata
+const MetadataSchemaPrefix = "#/components/schemas/"
+
+// errorType is the type of the error result of controller methods
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// InfoMetadata describes the chaincode
+type InfoMetadata struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// ParameterMetadata describes a parameter of a transaction
+type ParameterMetadata struct {
+	Name   string            `json:"name"`
+	Schema validators.Schema `json:"schema"`
+}
+
+// TransactionMetadata describes a transaction, i.e. a method of the controller. Parameters are in call order.
+type TransactionMetadata struct {
+	Name       string              `json:"name"`
+	Parameters []ParameterMetadata `json:"parameters"`
+	Returns    validators.Schema   `json:"returns,omitempty"`
+}
+
+// ContractMetadata describes the transactions of a contract
+type ContractMetadata struct {
+	Name         string                `json:"name"`
+	Transactions []TransactionMetadata `json:"transactions"`
+}
+
+// ComponentMetadata holds the schemas referred to by the transactions
+type ComponentMetadata struct {
+	Schemas map[string]validators.Schema `json:"schemas"`
+}
+
+// ChaincodeMetadata is a contract metadata document in the layout of the Fabric contract apis
+type ChaincodeMetadata struct {
+	Info       InfoMetadata                `json:"info"`
+	Contracts  map[string]ContractMetadata `json:"contracts"`
+	Components ComponentMetadata           `json:"components"`
+}
+
+// BuildMetadata describes the exported methods of obj callable through ExecuteMethod, and the schemas of the
+// structs they take and return. params gives the parameter names of each method, as generated by cmd/methodparams.
+func BuildMetadata(obj interface{}, params map[string][]string) (*ChaincodeMetadata, error) {
+	builder := validators.NewSchemaBuilder(MetadataSchemaPrefix)
+	objType := reflect.TypeOf(obj)
+	contract := ContractMetadata{Name: ChaincodeName, Transactions: []TransactionMetadata{}}
+	for i := 0; i < objType.NumMethod(); i++ {
+		method := objType.Method(i)
+		// the receiver is the first input of a method obtained from its type
+		methodType := method.Type
+		names, ok := params[method.Name]
+		if !ok || len(names) != methodType.NumIn()-1 {
+			return nil, fmt.Errorf("Error in building metadata: parameter names of %s are missing or out of date, run go generate", method.Name)
+		}
+		transaction := TransactionMetadata{Name: method.Name, Parameters: []ParameterMetadata{}}
+		for in := 1; in < methodType.NumIn(); in++ {
+			if methodType.In(in) == TxContextType {
+				continue
+			}
+			transaction.Parameters = append(transaction.Parameters, ParameterMetadata{Name: names[in-1], Schema: builder.ParameterSchema(methodType.In(in))})
+		}
+		if methodType.NumOut() > 0 && methodType.Out(0) != errorType {
+			transaction.Returns = builder.Schema(methodType.Out(0))
+			if methodType.NumOut() > 2 && methodType.Out(1) == bookmarkType {
+				transaction.Returns = validators.Schema{"type": "object", "properties": validators.Schema{
+					"Results":  transaction.Returns,
+					"Bookmark": validators.Schema{"type": "string"},
+				}}
+			}
+		}
+		contract.Transactions = append(contract.Transactions, transaction)
+	}
+	return &ChaincodeMetadata{
+		Info:       InfoMetadata{Title: ChaincodeName, Version: ChaincodeVersion},
+		Contracts:  map[string]ContractMetadata{ChaincodeName: contract},
+		Components: ComponentMetadata{Schemas: builder.Definitions},
+	}, nil
+}
@@ -0,0 +1,140 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// NamedArgsSuffix selects named-argument invocation when appended to the function name, e.g. GetSupplierById@named.
+// The only argument is then a JSON object mapping parameter names to values.
+const NamedArgsSuffix = "@named"
+
+// methodRegistry holds the parameter names of the methods ExecuteMethod calls, generated from the source, and the
+// defaults of their optional parameters
+var methodRegistry = struct {
+	params   map[string][]string
+	defaults map[string]map[string]string
+}{}
+
+// RegisterMethods registers the parameter names of the controller methods, as generated by cmd/methodparams, and
+// the values their optional parameters take when omitted. A default of "" stands for the zero value.
+func RegisterMethods(params map[string][]string, defaults map[string]map[string]string) {
+	methodRegistry.params = params
+	methodRegistry.defaults = defaults
+}
+
+// MethodDefaults returns the defaults of the optional parameters of the method, by parameter name
+func MethodDefaults(method string) map[string]string {
+	return methodRegistry.defaults[method]
+}
+
+// CheckMethodDefaults reports the defaults registered for parameters the methods do not have
+func CheckMethodDefaults() []string {
+	var problems []string
+	for method, defaults := range methodRegistry.defaults {
+		names, ok := methodRegistry.params[method]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("defaults given for unknown method %s", method))
+			continue
+		}
+		for name := range defaults {
+			if !containsName(names, name) {
+				problems = append(problems, fmt.Sprintf("default given for unknown parameter %s of %s", name, method))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func containsName(names []string, name string) bool {
+	for _, candidate := range names {
+		if candidate == name {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultArg returns the value of an omitted parameter, or an error when the parameter is not optional
+func defaultArg(method string, name string, argType reflect.Type) (reflect.Value, error) {
+	value, ok := methodRegistry.defaults[method][name]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("Argument %s of %s is missing and has no default", name, method)
+	}
+	if value == "" {
+		return reflect.Zero(argType), nil
+	}
+	converted, err := convert(argType.Kind(), value, argType)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("Default of argument %s of %s is invalid: %s", name, method, err.Error())
+	}
+	return converted, nil
+}
+
+// processNamedArgs converts the JSON object of named arguments into the parameters of the method. Omitted or null
+// arguments take their defaults, and names the method does not have are rejected.
+func processNamedArgs(inputArgTypes reflect.Type, args []string, functionName string, ctx *TxContext) ([]reflect.Value, error) {
+	names, ok := methodRegistry.params[functionName]
+	if !ok || len(names) != inputArgTypes.NumIn() {
+		return nil, fmt.Errorf("Parameter names of %s are not known, run go generate", functionName)
+	}
+	if len(args) != 1 {
+		return nil, fmt.Errorf("Named invocation of %s takes one JSON object argument, given %d arguments", functionName, len(args))
+	}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(args[0])))
+	decoder.UseNumber()
+	var named map[string]json.RawMessage
+	if err := decoder.Decode(&named); err != nil || named == nil {
+		return nil, fmt.Errorf("Named arguments of %s must be a JSON object", functionName)
+	}
+
+	result := make([]reflect.Value, inputArgTypes.NumIn())
+	first := 0
+	if takesContext(inputArgTypes) {
+		result[0] = reflect.ValueOf(ctx)
+		first = 1
+	}
+	for name := range named {
+		if index := indexOfName(names, name); index < first {
+			return nil, fmt.Errorf("%s has no argument %s, its arguments are %s", functionName, name, strings.Join(names[first:], ", "))
+		}
+	}
+	for i := first; i < inputArgTypes.NumIn(); i++ {
+		argType := inputArgTypes.In(i)
+		raw, given := named[names[i]]
+		if !given || isNull(raw) {
+			value, err := defaultArg(functionName, names[i], argType)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = value
+			continue
+		}
+		// scalars may be given as JSON values or as the strings positional arguments would be
+		value, err := convertJSON(raw, argType)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %s of %s: %s", names[i], functionName, err.Error())
+		}
+		result[i] = value
+	}
+	return result, nil
+}
+
+func indexOfName(names []string, name string) int {
+	for i, candidate := range names {
+		if candidate == name {
+			return i
+		}
+	}
+	return -1
+}
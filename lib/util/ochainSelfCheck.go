@@ -0,0 +1,48 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package util
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Problems is the error of a failed self-check, listing every problem found
+type Problems []string
+
+func (p Problems) Error() string {
+	return fmt.Sprintf("Error in self-check: %s", strings.Join(p, "; "))
+}
+
+// CheckController reports the controller methods ExecuteMethod cannot call: invalid signatures, parameter names
+// missing from the registry and optional parameters whose defaults are unknown or cannot be converted.
+func CheckController(obj interface{}) []string {
+	var problems []string
+	objValue := reflect.ValueOf(obj)
+	for i := 0; i < objValue.NumMethod(); i++ {
+		name := objValue.Type().Method(i).Name
+		methodType := objValue.Method(i).Type()
+		if err := CheckSignature(methodType); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, err.Error()))
+		}
+		names, ok := methodRegistry.params[name]
+		if !ok || len(names) != methodType.NumIn() {
+			problems = append(problems, fmt.Sprintf("%s: parameter names are not registered, run go generate", name))
+			continue
+		}
+		for index, param := range names {
+			if _, optional := methodRegistry.defaults[name][param]; optional {
+				if _, err := defaultArg(name, param, methodType.In(index)); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %s", name, err.Error()))
+				}
+			}
+		}
+	}
+	sort.Strings(problems)
+	return append(problems, CheckMethodDefaults()...)
+}
@@ -0,0 +1,118 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Bookmark continues a paged result: passed back to the same method it returns the next page. It is empty on the
+// last page.
+type Bookmark string
+
+// PagedResult is the response of a method returning a value and a Bookmark
+type PagedResult struct {
+	Results  interface{} `json:"Results"`
+	Bookmark Bookmark    `json:"Bookmark"`
+}
+
+var bookmarkType = reflect.TypeOf(Bookmark(""))
+
+// CheckSignature reports why a method cannot be called through ExecuteMethod. A method may take the
+// *TxContext first, followed by parameters convert supports, and return one of
+//
+//	error
+//	value
+//	(value, error)
+//	(value, Bookmark, error)
+func CheckSignature(methodType reflect.Type) error {
+	var problems []string
+	for i := 0; i < methodType.NumIn(); i++ {
+		in := methodType.In(i)
+		if in == TxContextType {
+			if i != 0 {
+				problems = append(problems, fmt.Sprintf("the transaction context must be the first parameter, found at %d", i+1))
+			}
+			continue
+		}
+		if !supportedArg(in) {
+			problems = append(problems, fmt.Sprintf("parameter %d of type %s cannot be converted from an argument", i+1, in))
+		}
+	}
+	if methodType.IsVariadic() {
+		problems = append(problems, "variadic parameters are not supported")
+	}
+	outs := make([]string, methodType.NumOut())
+	for i := range outs {
+		switch methodType.Out(i) {
+		case errorType:
+			outs[i] = "error"
+		case bookmarkType:
+			outs[i] = "bookmark"
+		default:
+			outs[i] = "value"
+		}
+	}
+	switch strings.Join(outs, ",") {
+	case "error", "value", "value,error", "value,bookmark,error":
+	default:
+		problems = append(problems, fmt.Sprintf("returns (%s), expected error, value, (value, error) or (value, Bookmark, error)", strings.Join(outs, ", ")))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// supportedArg reports whether convert can produce values of type t
+func supportedArg(t reflect.Type) bool {
+	if _, ok := argDecoders[t]; ok {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Struct,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Ptr:
+		return supportedArg(t.Elem())
+	case reflect.Slice, reflect.Map:
+		// elements not decoded one by one are left to encoding/json
+		return !decodedPerElement(t.Elem()) || supportedArg(t.Elem())
+	}
+	return false
+}
+
+// methodResponse turns the results of a method with a valid signature into the transaction response
+func methodResponse(result []reflect.Value) peer.Response {
+	if last := len(result) - 1; last >= 0 && result[last].Type() == errorType {
+		if resultError := result[last].Interface(); resultError != nil {
+			return shim.Error(fmt.Sprintf("ExecuteMethod: Error: %s", resultError.(error).Error()))
+		}
+		result = result[:last]
+	}
+	var returnObj interface{}
+	switch len(result) {
+	case 0:
+		return shim.Success(nil)
+	case 1:
+		returnObj = result[0].Interface()
+	default:
+		returnObj = PagedResult{Results: result[0].Interface(), Bookmark: result[1].Interface().(Bookmark)}
+	}
+	returnBytes, errMarshal := json.Marshal(returnObj)
+	if errMarshal != nil {
+		return shim.Error(fmt.Sprintf("ExecuteMethod: Marshalling response Error: %s", errMarshal.Error()))
+	}
+	return shim.Success(returnBytes)
+}
@@ -10,8 +10,6 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"strconv"
-	"time"
 	"unicode"
 	"strings"
 	"example.com/fffffefe/lib/util/validators"
@@ -21,10 +19,15 @@ import (
 	"github.com/hyperledger/fabric-protos-go/peer"
 )
 
-// Stub stores the ChaincodeStub for the current chaincode
+// Stub stores the ChaincodeStub for the current chaincode.
+//
+// Deprecated: it is shared by all transactions, take a *TxContext instead.
 var Stub shim.ChaincodeStubInterface
 var ChaincodeName string
 
+// ChaincodeVersion is the version reported in the contract metadata
+var ChaincodeVersion = "1.0.0"
+
 // Get ChaincodeStubInterface
 func GetStub() shim.ChaincodeStubInterface {
 	return Stub
@@ -130,162 +133,86 @@ func makeFirstLetterLowerCaps(input string) string {
 	return string(runes)
 }
 
-func convert(argKind reflect.Kind, arg string, argType reflect.Type) (reflect.Value, error) {
-	switch argKind {
-	case reflect.Bool:
-		val, err := strconv.ParseBool(arg)
-		if err == nil {
-			return reflect.ValueOf(val).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Int:
-		val, err := strconv.ParseInt(arg, 10, 64)
-		if err == nil {
-			return reflect.ValueOf(int(val)).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Int8:
-		val, err := strconv.ParseInt(arg, 10, 8)
-		if err == nil {
-			return reflect.ValueOf(int8(val)).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Int16:
-		val, err := strconv.ParseInt(arg, 10, 16)
-		if err == nil {
-			return reflect.ValueOf(int16(val)).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Int32:
-		val, err := strconv.ParseInt(arg, 10, 32)
-		if err == nil {
-			return reflect.ValueOf(int32(val)).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Int64:
-		val, err := time.ParseDuration(arg)
-		if err == nil {
-			return reflect.ValueOf(val).Convert(argType), err
-		} else if val, err := strconv.ParseInt(arg, 10, 64); err == nil {
-			return reflect.ValueOf(val).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Uint:
-		val, err := strconv.ParseUint(arg, 10, 64)
-		if err == nil {
-			return reflect.ValueOf(uint(val)).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Uint8:
-		val, err := strconv.ParseUint(arg, 10, 8)
-		if err == nil {
-			return reflect.ValueOf(uint8(val)).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Uint16:
-		val, err := strconv.ParseUint(arg, 10, 16)
-		if err == nil {
-			return reflect.ValueOf(uint16(val)).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Uint32:
-		val, err := strconv.ParseUint(arg, 10, 32)
-		if err == nil {
-			return reflect.ValueOf(uint32(val)).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Uint64:
-		val, err := strconv.ParseUint(arg, 10, 64)
-		if err == nil {
-			return reflect.ValueOf(val).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Uintptr:
-		val, err := strconv.ParseUint(arg, 10, 64)
-		if err == nil {
-			return reflect.ValueOf(uintptr(val)).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Float32:
-		val, err := strconv.ParseFloat(arg, 32)
-		if err == nil {
-			return reflect.ValueOf(float32(val)).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.Float64:
-		val, err := strconv.ParseFloat(arg, 64)
-		if err == nil {
-			return reflect.ValueOf(val).Convert(argType), err
-		}
-		return reflect.ValueOf((interface{})(nil)), err
-	case reflect.String:
-		return reflect.ValueOf(arg).Convert(argType), nil
-	case reflect.Slice:
-		ref := reflect.New(argType)
-		ref.Elem().Set(reflect.MakeSlice(argType, 0, 0))
-		if err := json.Unmarshal([]byte(arg), ref.Interface()); err != nil {
-			return reflect.ValueOf((interface{})(nil)), err
-		}
-		return ref.Elem().Convert(argType), nil
-	case reflect.Map:
-		ref := reflect.New(argType)
-		ref.Elem().Set(reflect.MakeMap(argType))
-		if err := json.Unmarshal([]byte(arg), ref.Interface()); err != nil {
-			return reflect.ValueOf((interface{})(nil)), err
-		}
-		return ref.Elem().Convert(argType), nil
-	case reflect.Struct:
-		var obj interface{}
-		if err := json.Unmarshal([]byte(arg), &obj); err != nil {
-			return reflect.ValueOf((interface{})(nil)), err
-		}
-		inputArgMap := obj.(map[string]interface{})
-		for i := 0; i < argType.NumField(); i++ {
-			mandatoryTagValue := argType.Field(i).Tag.Get("mandatory")
-			if mandatoryTagValue == "true" {
-				_, ok := inputArgMap[argType.Field(i).Name]
-				if !ok {
-					_, ok2 := inputArgMap[makeFirstLetterLowerCaps(argType.Field(i).Name)]
-					if !ok2 {
-						return reflect.ValueOf((interface{})(nil)), fmt.Errorf("Mandatory field %s for asset %s is not present in the input", argType.Field(i).Name, strings.Split(argType.String(), ".")[1])
-					}
+func makeFirstLetterUpperCaps(input string) string {
+	runes := []rune(input)
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
+
+// UnmarshalAsset parses the JSON object input into the struct asset points to, as a method argument is parsed:
+// mandatory fields must be present, absent fields keep their defaults and the result must pass ValidateStruct
+func UnmarshalAsset(input string, asset interface{}) error {
+	argType := reflect.TypeOf(asset).Elem()
+	var obj interface{}
+	if err := json.Unmarshal([]byte(input), &obj); err != nil {
+		return err
+	}
+	inputArgMap, ok := obj.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Argument Parsing/Validation failed: %s is not a JSON object", argType.Name())
+	}
+	for i := 0; i < argType.NumField(); i++ {
+		mandatoryTagValue := argType.Field(i).Tag.Get("mandatory")
+		if mandatoryTagValue == "true" {
+			_, ok := inputArgMap[argType.Field(i).Name]
+			if !ok {
+				_, ok2 := inputArgMap[makeFirstLetterLowerCaps(argType.Field(i).Name)]
+				if !ok2 {
+					return fmt.Errorf("Mandatory field %s for asset %s is not present in the input", argType.Field(i).Name, argType.Name())
 				}
 			}
 		}
-		ref := reflect.New(argType)
-		if err := defaults.Set(ref.Interface()); err != nil {
-			return reflect.ValueOf((interface{})(nil)), err
-		}
-		if err := json.Unmarshal([]byte(arg), ref.Interface()); err != nil {
-			return reflect.ValueOf((interface{})(nil)), err
-		}
-		err := validators.ValidateStruct(ref.Interface())
-		if err != nil {
-			return reflect.ValueOf((interface{})(nil)), err
-		}
-		val := ref.Elem().Convert(argType)
-		return val, nil
-	case reflect.Ptr:
-		ref := reflect.New(argType.Elem())
-		return ref.Elem().Convert(argType), nil
 	}
-	return reflect.ValueOf((interface{})(nil)), fmt.Errorf(("Argument Parsing/Validation failed: argument kind does not match supported kinds"))
+	if err := defaults.Set(asset); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(input), asset); err != nil {
+		return err
+	}
+	return validators.ValidateStruct(asset)
+}
+
+// takesContext reports whether the method's first parameter is the transaction context
+func takesContext(inputArgTypes reflect.Type) bool {
+	return inputArgTypes.NumIn() > 0 && inputArgTypes.In(0) == TxContextType
 }
 
-func processArgs(inputArgTypes reflect.Type, args []string, functionName string) ([]reflect.Value, error) {
+func processArgs(inputArgTypes reflect.Type, args []string, functionName string, ctx *TxContext) ([]reflect.Value, error) {
 	result := make([]reflect.Value, inputArgTypes.NumIn())
+	first := 0
+	if takesContext(inputArgTypes) {
+		result[0] = reflect.ValueOf(ctx)
+		first = 1
+	}
 
-	if inputArgTypes.NumIn() != len(args) {
-		if (functionName == "Init" && len(args) == 1 && args[0] == "") {
-			dummyresult := make([]reflect.Value, 0)
-			return dummyresult, nil 
+	if inputArgTypes.NumIn()-first != len(args) {
+		// Init may be called without arguments, in which case its parameters get their zero values
+		if functionName == "Init" && (len(args) == 0 || (len(args) == 1 && args[0] == "")) {
+			for i := first; i < inputArgTypes.NumIn(); i++ {
+				result[i] = reflect.Zero(inputArgTypes.In(i))
+			}
+			return result, nil
+		}
+		mismatch := fmt.Errorf("Number of input arguments required by the function %s are %d, which did not match the number arguments passed i.e %d", functionName, inputArgTypes.NumIn()-first, len(args))
+		// trailing parameters with defaults may be omitted, so that adding an optional parameter keeps old clients working
+		names := methodRegistry.params[functionName]
+		if len(args) > inputArgTypes.NumIn()-first || len(names) != inputArgTypes.NumIn() {
+			return nil, mismatch
+		}
+		for i := first + len(args); i < inputArgTypes.NumIn(); i++ {
+			value, err := defaultArg(functionName, names[i], inputArgTypes.In(i))
+			if err != nil {
+				return nil, mismatch
+			}
+			result[i] = value
 		}
-		return nil, fmt.Errorf("Number of input arguments required by the function %s are %d, which did not match the number arguments passed i.e %d", functionName, inputArgTypes.NumIn(), len(args))
 	}
 
-	for i := 0; i < inputArgTypes.NumIn(); i++ {
+	for i := first; i < first+len(args); i++ {
 		// fmt.Println(inputArgTypes.In(i).Kind(), args[i])
-		response, err := convert(inputArgTypes.In(i).Kind(), args[i], inputArgTypes.In(i))
+		response, err := convert(inputArgTypes.In(i).Kind(), args[i-first], inputArgTypes.In(i))
 		// fmt.Println("Response", response)
 		if err == nil {
 			result[i] = response
@@ -297,10 +224,23 @@ func processArgs(inputArgTypes reflect.Type, args []string, functionName string)
 	return result, nil
 }
 
-// ExecuteMethod calls a method with the given name on the provided reciever
+// ExecuteMethod calls a method with the given name on the provided reciever. Methods whose first parameter is a
+// *TxContext receive the context of the transaction there, the other parameters are converted from args, in order,
+// or by name from a single JSON object when the function name ends with NamedArgsSuffix.
+// The global Stub is still set for code which does not take a context yet.
 func ExecuteMethod(obj interface{}, function string, stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	Stub = stub
+	ctx := NewTxContext(stub)
+	SetCurrentContext(ctx)
+	named := strings.HasSuffix(function, NamedArgsSuffix)
+	function = strings.TrimSuffix(function, NamedArgsSuffix)
 	methodValue := reflect.ValueOf(obj).MethodByName(function)
+	if methodValue.IsValid() != true {
+		// custom methods are declared in lower camel case in the spec, but only exported methods can be called
+		methodValue = reflect.ValueOf(obj).MethodByName(makeFirstLetterUpperCaps(function))
+		if methodValue.IsValid() {
+			function = makeFirstLetterUpperCaps(function)
+		}
+	}
 	if methodValue.IsValid() != true {
 		return shim.Error(fmt.Sprintf("ExecuteMethod: No method found by given name - %s", function))
 	}
@@ -324,20 +264,17 @@ func ExecuteMethod(obj interface{}, function string, stub shim.ChaincodeStubInte
 	// 	}
 	// 	return shim.Success(returnBytes)
 	// }
-	convertedArgs, err := processArgs(methodValue.Type(), args, function)
+	if err := CheckSignature(methodValue.Type()); err != nil {
+		return shim.Error(fmt.Sprintf("ExecuteMethod: %s cannot be called: %s", function, err.Error()))
+	}
+	process := processArgs
+	if named {
+		process = processNamedArgs
+	}
+	convertedArgs, err := process(methodValue.Type(), args, function, ctx)
 	// fmt.Println(convertedArgs)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("Error in argument parsing and validation Detailed Error : %s", err.Error()))
 	}
-	result := methodValue.Call(convertedArgs)
-	resultError := result[1].Interface()
-	if resultError != nil {
-		return shim.Error(fmt.Sprintf("ExecuteMethod: Error: %s", resultError.(error).Error()))
-	}
-	returnObj := result[0].Interface()
-	returnBytes, errMarshal := json.Marshal(returnObj)
-	if errMarshal != nil {
-		return shim.Error(fmt.Sprintf("ExecuteMethod: Marshalling response Error: %s", errMarshal.Error()))
-	}
-	return shim.Success(returnBytes)
+	return methodResponse(methodValue.Call(convertedArgs))
 }
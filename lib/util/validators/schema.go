@@ -0,0 +1,409 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package validators
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/fffffefe/lib/util/date"
+	"example.com/fffffefe/lib/util/decimal"
+	"github.com/creasty/defaults"
+)
+
+const (
+	// SchemaDialect is the JSON Schema draft the schemas are written in
+	SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+	// DecimalKeyword marks the schema of a decimal. The value, given as a string or a number, is read as decimal text:
+	// pattern applies to that text and minimum and maximum compare it exactly. Validators which do not know the
+	// keyword only check the pattern of decimals given as strings and the bounds of decimals given as numbers.
+	DecimalKeyword = "x-decimal"
+	// ValidateKeyword keeps the validate tag a schema was translated from
+	ValidateKeyword = "x-validate"
+	// IdKeyword marks the field holding the ledger key of an asset
+	IdKeyword = "x-id"
+	// urlPattern requires the scheme, host and path which checkURL requires
+	urlPattern = `^[a-zA-Z][a-zA-Z0-9+.-]*://[^/?#]+/`
+)
+
+// Schema is a JSON Schema document
+type Schema map[string]interface{}
+
+var (
+	dateType    = reflect.TypeOf(date.Date{})
+	decimalType = reflect.TypeOf(decimal.Decimal{})
+)
+
+// SchemaBuilder translates Go types into JSON Schema. Structs are described once, in Definitions under their
+// type name, and referred to with RefPrefix followed by the name.
+type SchemaBuilder struct {
+	RefPrefix   string
+	Definitions map[string]Schema
+}
+
+// NewSchemaBuilder returns a builder whose struct references start with refPrefix, e.g. #/components/schemas/
+func NewSchemaBuilder(refPrefix string) *SchemaBuilder {
+	return &SchemaBuilder{RefPrefix: refPrefix, Definitions: make(map[string]Schema)}
+}
+
+// JSONSchema returns the draft 2020-12 schema of the asset prototype, with the structs it embeds in $defs.
+// A document is accepted by the schema when parsing it as a method argument, i.e. the mandatory check, defaults,
+// unmarshalling and ValidateStruct, accepts it, with these exceptions: null values, field names in another case,
+// and defaults of structs inside arrays, which are not applied to the elements.
+func JSONSchema(prototype interface{}) Schema {
+	assetType := reflect.TypeOf(prototype)
+	for assetType.Kind() == reflect.Ptr {
+		assetType = assetType.Elem()
+	}
+	builder := NewSchemaBuilder("#/$defs/")
+	builder.Schema(assetType)
+	schema := Schema{
+		"$schema": SchemaDialect,
+		"$id":     assetType.Name(),
+		"title":   assetType.Name(),
+	}
+	for keyword, value := range builder.Definitions[assetType.Name()] {
+		schema[keyword] = value
+	}
+	if required := requiredFields(assetType, schema["required"]); len(required) > 0 {
+		schema["required"] = required
+	}
+	schema["$defs"] = builder.Definitions
+	return schema
+}
+
+// MandatoryFields returns the json names of the fields of struct type t tagged mandatory. Parsing checks that they
+// are present in the document given for t itself, not in the documents of the structs t embeds.
+func MandatoryFields(t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	mandatory := []string{}
+	if t.Kind() != reflect.Struct {
+		return mandatory
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get(MandatoryTag) == "true" {
+			mandatory = append(mandatory, jsonName(t.Field(i)))
+		}
+	}
+	return mandatory
+}
+
+// requiredFields returns the mandatory fields of t followed by the other required fields
+func requiredFields(t reflect.Type, required interface{}) []string {
+	fields := MandatoryFields(t)
+	others, _ := required.([]string)
+	for _, name := range others {
+		if !containsString(fields, name) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// ParameterSchema returns the schema of a method parameter of type t. Unlike the definition of an embedded
+// struct, it requires the mandatory fields.
+func (b *SchemaBuilder) ParameterSchema(t reflect.Type) Schema {
+	schema := b.Schema(t)
+	if mandatory := MandatoryFields(t); len(mandatory) > 0 {
+		schema["required"] = mandatory
+	}
+	return schema
+}
+
+// Schema returns the schema of values of type t
+func (b *SchemaBuilder) Schema(t reflect.Type) Schema {
+	switch {
+	case t == dateType:
+		return Schema{"type": "string", "anyOf": []Schema{{"format": "date"}, {"format": "date-time"}}}
+	case t == decimalType:
+		return Schema{"type": []string{"string", "number"}, "pattern": decimalPattern(decimal.MaxScale), DecimalKeyword: true}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.Schema(t.Elem())
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": b.Schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": b.Schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.Definitions[t.Name()]; !ok {
+			// registered before the fields are described, so that recursive types terminate
+			b.Definitions[t.Name()] = Schema{}
+			b.Definitions[t.Name()] = b.structSchema(t)
+		}
+		return Schema{"$ref": b.RefPrefix + t.Name()}
+	}
+	// interface{} and other kinds accept any value
+	return Schema{}
+}
+
+// structSchema describes the json fields of a struct. The default, final and id tags give the default, readOnly
+// and x-id keywords, and the validate tag is translated into the keywords of the field. A field is required when
+// the value it has without input, its default or zero value, does not validate. Mandatory fields are added by
+// JSONSchema and ParameterSchema, as parsing checks them only on the outermost struct.
+func (b *SchemaBuilder) structSchema(t reflect.Type) Schema {
+	absent := reflect.New(t)
+	if err := defaults.Set(absent.Interface()); err != nil {
+		absent = reflect.New(t)
+	}
+	properties := Schema{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		property := b.Schema(field.Type)
+		if final, ok := field.Tag.Lookup("final"); ok {
+			// the ledger sets final fields itself, whatever the input gives
+			property["default"], property["readOnly"] = final, true
+		}
+		if defaultValue, ok := field.Tag.Lookup("default"); ok {
+			property["default"] = typedDefault(field.Type, defaultValue)
+		}
+		if field.Tag.Get("id") == "true" {
+			property[IdKeyword] = true
+		}
+		validate := field.Tag.Get("validate")
+		if validate != "" {
+			property[ValidateKeyword] = validate
+			translateTags(property, field.Type, validate)
+		}
+		properties[name] = property
+		if !validAbsent(absent.Elem().Field(i), validate) {
+			required = append(required, name)
+		}
+	}
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// validAbsent reports whether the value a field has when it is not given passes its validations
+func validAbsent(value reflect.Value, validate string) bool {
+	if validate != "" && Validate(value.Interface(), validate) != nil {
+		return false
+	}
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Struct && value.Type() != dateType && value.Type() != decimalType {
+		return ValidateStruct(value.Addr().Interface()) == nil
+	}
+	return true
+}
+
+// splitTags splits a validate tag on the commas which are not escaped, as validator.v2 does
+func splitTags(validate string) []string {
+	tags := []string{}
+	current := ""
+	for i := 0; i < len(validate); i++ {
+		if validate[i] == '\\' && i+1 < len(validate) && validate[i+1] == ',' {
+			current += ","
+			i++
+			continue
+		}
+		if validate[i] == ',' {
+			tags = append(tags, current)
+			current = ""
+			continue
+		}
+		current += string(validate[i])
+	}
+	return append(tags, current)
+}
+
+// translateTags adds the keywords equivalent to the validate tag to the schema of a field of type t.
+// Tags which have no equivalent, e.g. custom validators, are only kept in x-validate.
+func translateTags(schema Schema, t reflect.Type, validate string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var patterns []Schema
+	for _, tag := range splitTags(validate) {
+		parts := strings.SplitN(tag, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		param := ""
+		if len(parts) > 1 {
+			param = strings.TrimSpace(parts[1])
+		}
+		switch {
+		case t == decimalType:
+			switch name {
+			case DecimalMinTag:
+				schema["minimum"] = decimalNumber(param)
+			case DecimalMaxTag:
+				schema["maximum"] = decimalNumber(param)
+			case PositiveTag:
+				schema["minimum"] = 0
+			case ScaleTag:
+				// scale limits the fractional digits written, 1.50 has scale 2 although it equals 1.5
+				if scale, err := strconv.Atoi(param); err == nil && scale >= 0 && scale < decimal.MaxScale {
+					schema["pattern"] = decimalPattern(scale)
+				}
+			}
+		case t == dateType:
+			switch name {
+			case MaxDateTag:
+				schema["formatExclusiveMaximum"] = normalizeDate(param)
+			case MinDateTag:
+				schema["formatExclusiveMinimum"] = normalizeDate(param)
+			}
+		case t.Kind() == reflect.String:
+			switch name {
+			case "min":
+				schema["minLength"] = atoi(param)
+			case "max":
+				schema["maxLength"] = atoi(param)
+			case "len":
+				schema["minLength"], schema["maxLength"] = atoi(param), atoi(param)
+			case "nonzero":
+				schema["minLength"] = 1
+			case "regexp":
+				patterns = append(patterns, Schema{"pattern": param})
+			case EmailTag:
+				schema["format"] = "email"
+			case URLTag:
+				schema["format"] = "uri"
+				patterns = append(patterns, Schema{"pattern": urlPattern})
+			case NumericTag:
+				patterns = append(patterns, Schema{"pattern": "^[0-9]+$"})
+			case PositiveTag:
+				patterns = append(patterns, Schema{"pattern": `^(\+?[0-9]+|-0+)$`})
+			}
+		case t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map:
+			minKeyword, maxKeyword := "minItems", "maxItems"
+			if t.Kind() == reflect.Map {
+				minKeyword, maxKeyword = "minProperties", "maxProperties"
+			}
+			switch name {
+			case "min":
+				schema[minKeyword] = atoi(param)
+			case "max":
+				schema[maxKeyword] = atoi(param)
+			case "len":
+				schema[minKeyword], schema[maxKeyword] = atoi(param), atoi(param)
+			case "nonzero":
+				schema[minKeyword] = 1
+			case RangeTag:
+				bounds := strings.SplitN(param, "-", 2)
+				if bounds[0] != "" {
+					schema[minKeyword] = atoi(bounds[0])
+				}
+				if len(bounds) > 1 && bounds[1] != "" {
+					schema[maxKeyword] = atoi(bounds[1])
+				}
+			}
+		default:
+			switch name {
+			case "min":
+				schema["minimum"] = decimalNumber(param)
+			case "max":
+				schema["maximum"] = decimalNumber(param)
+			case "nonzero":
+				if t.Kind() == reflect.Bool {
+					schema["const"] = true
+				} else {
+					schema["not"] = Schema{"const": 0}
+				}
+			}
+		}
+	}
+	// a field has a single pattern keyword, further patterns must all match as well
+	if len(patterns) == 1 && schema["pattern"] == nil {
+		schema["pattern"] = patterns[0]["pattern"]
+	} else if len(patterns) > 0 {
+		schema["allOf"] = patterns
+	}
+}
+
+// decimalPattern matches the decimal text decimal.Parse accepts with at most scale fractional digits
+func decimalPattern(scale int) string {
+	if scale == 0 {
+		return `^[-+]?[0-9]+$`
+	}
+	return `^[-+]?[0-9]+(\.[0-9]{1,` + strconv.Itoa(scale) + `})?$`
+}
+
+func atoi(param string) int {
+	value, _ := strconv.Atoi(param)
+	return value
+}
+
+// decimalNumber returns a tag parameter as an exact JSON number
+func decimalNumber(param string) interface{} {
+	if parsed, err := strconv.ParseFloat(param, 64); err == nil && strconv.FormatFloat(parsed, 'f', -1, 64) == param {
+		return parsed
+	}
+	return json.Number(param)
+}
+
+// normalizeDate writes a date parameter of a validate tag in RFC 3339
+func normalizeDate(param string) string {
+	if parsed, err := parseTime(param); err == nil {
+		return parsed.UTC().Format(time.RFC3339)
+	}
+	return param
+}
+
+// typedDefault converts the text of a default tag to the JSON value of the field's type
+func typedDefault(t reflect.Type, value string) interface{} {
+	switch t.Kind() {
+	case reflect.Bool:
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return parsed
+		}
+	case reflect.Float32, reflect.Float64:
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return value
+}
@@ -0,0 +1,375 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package validators
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"example.com/fffffefe/lib/util/date"
+)
+
+var integerText = regexp.MustCompile(`^-?[0-9]+$`)
+
+// schemaEvaluator checks JSON values against the keywords JSONSchema writes, resolving $ref from the root document
+type schemaEvaluator struct {
+	root map[string]interface{}
+}
+
+// Validate checks the JSON document against the schema. It supports the keywords JSONSchema generates, asserting
+// the date, date-time, email and uri formats, and the x-decimal extension. Integers must be written without
+// fraction or exponent, as encoding/json requires for Go integers.
+func (s Schema) Validate(document []byte) error {
+	root, err := decodeJSON(mustMarshal(s))
+	if err != nil {
+		return fmt.Errorf("Schema Validation Error: invalid schema %s", err.Error())
+	}
+	instance, err := decodeJSON(document)
+	if err != nil {
+		return fmt.Errorf("Schema Validation Error: invalid document %s", err.Error())
+	}
+	rootSchema, ok := root.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Schema Validation Error: schema is not an object")
+	}
+	evaluator := &schemaEvaluator{root: rootSchema}
+	return evaluator.validate(rootSchema, instance, "")
+}
+
+func mustMarshal(s Schema) []byte {
+	schemaAsBytes, err := json.Marshal(s)
+	if err != nil {
+		return []byte("null")
+	}
+	return schemaAsBytes
+}
+
+// decodeJSON decodes a document keeping numbers as their text
+func decodeJSON(document []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the document")
+	}
+	return value, nil
+}
+
+// resolve returns the schema a local reference such as #/$defs/Supplier points to
+func (e *schemaEvaluator) resolve(ref string) (map[string]interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("Schema Validation Error: unsupported reference %s", ref)
+	}
+	var current interface{} = e.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Schema Validation Error: unresolved reference %s", ref)
+		}
+		if current, ok = object[token]; !ok {
+			return nil, fmt.Errorf("Schema Validation Error: unresolved reference %s", ref)
+		}
+	}
+	schema, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Schema Validation Error: reference %s is not a schema", ref)
+	}
+	return schema, nil
+}
+
+func (e *schemaEvaluator) validate(schema map[string]interface{}, instance interface{}, path string) error {
+	fail := func(format string, args ...interface{}) error {
+		location := path
+		if location == "" {
+			location = "/"
+		}
+		return fmt.Errorf("Schema Validation Error: %s %s", location, fmt.Sprintf(format, args...))
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := e.resolve(ref)
+		if err != nil {
+			return err
+		}
+		if err := e.validate(target, instance, path); err != nil {
+			return err
+		}
+	}
+	if expected, ok := schema["type"]; ok && !matchesType(expected, instance) {
+		return fail("is not of type %v", expected)
+	}
+	if expected, ok := schema["const"]; ok && !equalJSON(expected, instance) {
+		return fail("must be %v", expected)
+	}
+	if subschemas, ok := schema["allOf"].([]interface{}); ok {
+		for _, subschema := range subschemas {
+			if err := e.validate(asSchema(subschema), instance, path); err != nil {
+				return err
+			}
+		}
+	}
+	if subschemas, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, subschema := range subschemas {
+			if e.validate(asSchema(subschema), instance, path) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fail("matches none of anyOf")
+		}
+	}
+	if subschema, ok := schema["not"]; ok && e.validate(asSchema(subschema), instance, path) == nil {
+		return fail("must not match %v", subschema)
+	}
+
+	switch value := instance.(type) {
+	case map[string]interface{}:
+		return e.validateObject(schema, value, path, fail)
+	case []interface{}:
+		if err := checkCount(schema, "minItems", "maxItems", len(value), fail); err != nil {
+			return err
+		}
+		if items, ok := schema["items"]; ok {
+			for i, item := range value {
+				if err := e.validate(asSchema(items), item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if err := checkCount(schema, "minLength", "maxLength", utf8.RuneCountInString(value), fail); err != nil {
+			return err
+		}
+		if err := checkPattern(schema, value, fail); err != nil {
+			return err
+		}
+		if err := checkFormat(schema, value, fail); err != nil {
+			return err
+		}
+		if schema[DecimalKeyword] == true {
+			if number, ok := new(big.Rat).SetString(value); ok && !strings.ContainsAny(value, "eE/") {
+				return checkBounds(schema, number, fail)
+			}
+		}
+	case json.Number:
+		if schema[DecimalKeyword] == true {
+			if err := checkPattern(schema, value.String(), fail); err != nil {
+				return err
+			}
+		}
+		number, ok := new(big.Rat).SetString(value.String())
+		if !ok {
+			return fail("is not a number")
+		}
+		return checkBounds(schema, number, fail)
+	}
+	return nil
+}
+
+func (e *schemaEvaluator) validateObject(schema map[string]interface{}, object map[string]interface{}, path string, fail func(string, ...interface{}) error) error {
+	if err := checkCount(schema, "minProperties", "maxProperties", len(object), fail); err != nil {
+		return err
+	}
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, present := object[fmt.Sprint(name)]; !present {
+				return fail("misses required property %v", name)
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	for name, value := range object {
+		propertyPath := path + "/" + name
+		if property, ok := properties[name]; ok {
+			if err := e.validate(asSchema(property), value, propertyPath); err != nil {
+				return err
+			}
+		} else if additional, ok := schema["additionalProperties"]; ok {
+			if err := e.validate(asSchema(additional), value, propertyPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// asSchema returns a subschema as an object. The boolean schema false becomes a schema nothing matches.
+func asSchema(subschema interface{}) map[string]interface{} {
+	switch value := subschema.(type) {
+	case map[string]interface{}:
+		return value
+	case bool:
+		if !value {
+			return map[string]interface{}{"not": map[string]interface{}{}}
+		}
+	}
+	return map[string]interface{}{}
+}
+
+func jsonType(instance interface{}) string {
+	switch value := instance.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if integerText.MatchString(value.String()) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return ""
+}
+
+func matchesType(expected interface{}, instance interface{}) bool {
+	actual := jsonType(instance)
+	matches := func(name interface{}) bool {
+		return name == actual || (name == "number" && actual == "integer")
+	}
+	if names, ok := expected.([]interface{}); ok {
+		for _, name := range names {
+			if matches(name) {
+				return true
+			}
+		}
+		return false
+	}
+	return matches(expected)
+}
+
+// equalJSON compares JSON values, numbers by their value
+func equalJSON(a interface{}, b interface{}) bool {
+	numberA, okA := a.(json.Number)
+	numberB, okB := b.(json.Number)
+	if okA && okB {
+		ratA, okA := new(big.Rat).SetString(numberA.String())
+		ratB, okB := new(big.Rat).SetString(numberB.String())
+		return okA && okB && ratA.Cmp(ratB) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func schemaInt(schema map[string]interface{}, keyword string) (int, bool) {
+	number, ok := schema[keyword].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	value, err := number.Int64()
+	return int(value), err == nil
+}
+
+func checkCount(schema map[string]interface{}, minKeyword string, maxKeyword string, count int, fail func(string, ...interface{}) error) error {
+	if min, ok := schemaInt(schema, minKeyword); ok && count < min {
+		return fail("has %d elements, fewer than %s %d", count, minKeyword, min)
+	}
+	if max, ok := schemaInt(schema, maxKeyword); ok && count > max {
+		return fail("has %d elements, more than %s %d", count, maxKeyword, max)
+	}
+	return nil
+}
+
+func checkPattern(schema map[string]interface{}, value string, fail func(string, ...interface{}) error) error {
+	pattern, ok := schema["pattern"].(string)
+	if !ok {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fail("has an invalid pattern %s", pattern)
+	}
+	if !re.MatchString(value) {
+		return fail("%s does not match pattern %s", value, pattern)
+	}
+	return nil
+}
+
+func checkBounds(schema map[string]interface{}, number *big.Rat, fail func(string, ...interface{}) error) error {
+	bound := func(keyword string) (*big.Rat, bool) {
+		value, ok := schema[keyword].(json.Number)
+		if !ok {
+			return nil, false
+		}
+		return new(big.Rat).SetString(value.String())
+	}
+	if min, ok := bound("minimum"); ok && number.Cmp(min) < 0 {
+		return fail("%s is less than minimum %s", number.RatString(), min.RatString())
+	}
+	if max, ok := bound("maximum"); ok && number.Cmp(max) > 0 {
+		return fail("%s is greater than maximum %s", number.RatString(), max.RatString())
+	}
+	if multiple, ok := bound("multipleOf"); ok && multiple.Sign() > 0 {
+		if !new(big.Rat).Quo(number, multiple).IsInt() {
+			return fail("%s is not a multiple of %s", number.RatString(), multiple.RatString())
+		}
+	}
+	return nil
+}
+
+// parseFormatTime reads a date or date-time string as Date.UnmarshalJSON does
+func parseFormatTime(value string) (time.Time, bool) {
+	if parsed, err := time.Parse(date.CustomDateLayout, value); err == nil {
+		return parsed, true
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, true
+	}
+	return time.Time{}, false
+}
+
+func checkFormat(schema map[string]interface{}, value string, fail func(string, ...interface{}) error) error {
+	switch schema["format"] {
+	case "date":
+		if _, err := time.Parse(date.CustomDateLayout, value); err != nil {
+			return fail("%s is not a date", value)
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fail("%s is not a date-time", value)
+		}
+	case "email":
+		if !regexp.MustCompile(RegexEmail).MatchString(value) {
+			return fail("%s is not an email", value)
+		}
+	case "uri":
+		if parsed, err := url.Parse(value); err != nil || !parsed.IsAbs() {
+			return fail("%s is not a uri", value)
+		}
+	}
+	given, ok := parseFormatTime(value)
+	if !ok {
+		return nil
+	}
+	if bound, ok := schema["formatExclusiveMinimum"].(string); ok {
+		if min, ok := parseFormatTime(bound); ok && !given.After(min) {
+			return fail("%s is not after %s", value, bound)
+		}
+	}
+	if bound, ok := schema["formatExclusiveMaximum"].(string); ok {
+		if max, ok := parseFormatTime(bound); ok && !given.Before(max) {
+			return fail("%s is not before %s", value, bound)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package validators
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"example.com/fffffefe/lib/util/decimal"
+)
+
+// builtinTags are the validators validator.v2 provides itself
+var builtinTags = map[string]bool{"nonzero": true, "len": true, "min": true, "max": true, "regexp": true, "nonnil": true}
+
+// CheckTags reports the validate tags of t, and of the structs it contains, which name unknown validators or give
+// parameters their validator cannot read. validator.v2 only reports these when a value is validated.
+func CheckTags(t reflect.Type) []string {
+	var problems []string
+	checkStructTags(t, t.Name(), map[reflect.Type]bool{}, &problems)
+	return problems
+}
+
+func checkStructTags(t reflect.Type, path string, visited map[reflect.Type]bool, problems *[]string) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visited[t] || t == dateType || t == decimalType {
+		return
+	}
+	visited[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		fieldPath := path + "." + field.Name
+		if validate, ok := field.Tag.Lookup("validate"); ok {
+			for _, tag := range splitTags(validate) {
+				if problem := checkTag(tag, field.Type); problem != "" {
+					*problems = append(*problems, fmt.Sprintf("%s: %s", fieldPath, problem))
+				}
+			}
+		}
+		checkStructTags(field.Type, fieldPath, visited, problems)
+	}
+}
+
+// checkTag describes what is wrong with one tag of the validate tag of a field of type t, e.g. min=a
+func checkTag(tag string, t reflect.Type) string {
+	tag = strings.TrimSpace(tag)
+	if tag == "" || tag == "-" {
+		return ""
+	}
+	name, param := tag, ""
+	if index := strings.Index(tag, "="); index >= 0 {
+		name, param = tag[:index], tag[index+1:]
+	}
+	if _, ok := ValidatorMapping[name]; !ok && !builtinTags[name] {
+		return fmt.Sprintf("unknown validator %s", name)
+	}
+	switch name {
+	case "min", "max", "len":
+		if t == decimalType {
+			return fmt.Sprintf("%s does not apply to decimals, use %s or %s", name, DecimalMinTag, DecimalMaxTag)
+		}
+		if _, err := decimal.Parse(param); err != nil {
+			return fmt.Sprintf("%s needs a number, given %q", name, param)
+		}
+	case DecimalMinTag, DecimalMaxTag:
+		if t != decimalType {
+			return fmt.Sprintf("%s only applies to decimals", name)
+		}
+		if _, err := decimal.Parse(param); err != nil {
+			return fmt.Sprintf("%s needs a decimal, given %q", name, param)
+		}
+	case "regexp":
+		if _, err := regexp.Compile(param); err != nil {
+			return fmt.Sprintf("regexp %q does not compile: %s", param, err.Error())
+		}
+	case MaxDateTag, MinDateTag:
+		if _, err := parseTime(param); err != nil {
+			return fmt.Sprintf("%s needs a date, given %q", name, param)
+		}
+	case ScaleTag:
+		if _, err := strconv.Atoi(param); err != nil {
+			return fmt.Sprintf("%s needs an integer, given %q", name, param)
+		}
+	case RangeTag:
+		bounds := strings.Split(param, "-")
+		if len(bounds) != 2 {
+			return fmt.Sprintf("%s needs bounds written min-max, given %q", name, param)
+		}
+		for _, bound := range bounds {
+			if _, err := strconv.Atoi(bound); bound != "" && err != nil {
+				return fmt.Sprintf("%s needs integer bounds, given %q", name, param)
+			}
+		}
+	}
+	return ""
+}
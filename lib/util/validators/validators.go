@@ -14,6 +14,7 @@ import (
 	"strings"
 	"time"
 	"example.com/fffffefe/lib/util/date"
+	"example.com/fffffefe/lib/util/decimal"
 	
 	"gopkg.in/validator.v2"
 )
@@ -24,19 +25,23 @@ var validatorsInitialised bool = false
 // If a user should want to add his own validation functions then
 // he should define his own validation functions and mention in the map
 var ValidatorMapping = map[string]validator.ValidationFunc{
-	BooleanTag:   checkBoolean,
-	IntegerTag:   checkInteger,
-	StringTag:    checkString,
-	NumericTag:   checkNumeric,
-	PositiveTag:  checkPositive,
-	DateTag:      checkDate,
-	MaxDateTag:   checkMaxDate,
-	MinDateTag:   checkMinDate,
-	URLTag:       checkURL,
-	EmailTag:     checkEmail,
-	MandatoryTag: checkMandatory,
-	ArrayTag:     checkArray,
-	RangeTag:     checkRange,
+	BooleanTag:    checkBoolean,
+	IntegerTag:    checkInteger,
+	StringTag:     checkString,
+	NumericTag:    checkNumeric,
+	PositiveTag:   checkPositive,
+	DateTag:       checkDate,
+	MaxDateTag:    checkMaxDate,
+	MinDateTag:    checkMinDate,
+	URLTag:        checkURL,
+	EmailTag:      checkEmail,
+	MandatoryTag:  checkMandatory,
+	ArrayTag:      checkArray,
+	RangeTag:      checkRange,
+	DecimalTag:    checkDecimal,
+	ScaleTag:      checkScale,
+	DecimalMinTag: checkDecimalMin,
+	DecimalMaxTag: checkDecimalMax,
 }
 
 func initializeValidators() {
@@ -103,6 +108,12 @@ func checkNumeric(input interface{}, param string) error {
 }
 
 func checkPositive(input interface{}, param string) error {
+	if d, ok := input.(decimal.Decimal); ok {
+		if d.Sign() < 0 {
+			return fmt.Errorf("Positive Validation: input is not positive %s", d.String())
+		}
+		return nil
+	}
 	inputValue := reflect.ValueOf(input)
 	if inputValue.Kind() != reflect.String {
 		return fmt.Errorf("Positive Validation: input is not string %v ", input)
@@ -284,3 +295,55 @@ func checkMaxDate(input interface{}, param string) error {
 	}
 	return nil
 }
+
+func checkDecimal(input interface{}, param string) error {
+	if _, ok := input.(decimal.Decimal); !ok {
+		return fmt.Errorf("Decimal Validation Error: input is not a decimal %v", input)
+	}
+	return nil
+}
+
+func checkScale(input interface{}, param string) error {
+	d, ok := input.(decimal.Decimal)
+	if !ok {
+		return fmt.Errorf("Scale Validation Error: input is not a decimal %v", input)
+	}
+	scale, err := strconv.Atoi(param)
+	if err != nil {
+		return fmt.Errorf("Scale Validation Error: scale %s is not a number", param)
+	}
+	if int(d.Scale()) > scale {
+		return fmt.Errorf("Scale Validation Error: %s has more than %d fractional digits", d.String(), scale)
+	}
+	return nil
+}
+
+func checkDecimalMin(input interface{}, param string) error {
+	d, ok := input.(decimal.Decimal)
+	if !ok {
+		return fmt.Errorf("Min Validation Error: input is not a decimal %v", input)
+	}
+	min, err := decimal.Parse(param)
+	if err != nil {
+		return fmt.Errorf("Min Validation Error: min %s", err.Error())
+	}
+	if d.Cmp(min) < 0 {
+		return fmt.Errorf("Min Validation Error: %s is less than min %s", d.String(), param)
+	}
+	return nil
+}
+
+func checkDecimalMax(input interface{}, param string) error {
+	d, ok := input.(decimal.Decimal)
+	if !ok {
+		return fmt.Errorf("Max Validation Error: input is not a decimal %v", input)
+	}
+	max, err := decimal.Parse(param)
+	if err != nil {
+		return fmt.Errorf("Max Validation Error: max %s", err.Error())
+	}
+	if d.Cmp(max) > 0 {
+		return fmt.Errorf("Max Validation Error: %s is greater than max %s", d.String(), param)
+	}
+	return nil
+}
@@ -7,14 +7,17 @@ package main
 
 import (
 	"fmt"
-	"example.com/fffffefe/lib/chaincode"
 	"example.com/fffffefe/lib/util"
-	"github.com/hyperledger/fabric-chaincode-go/shim"
 )
 
+//go:generate go run ./cmd/couchindexes
+//go:generate go run ./cmd/methodparams
+
+// main starts the chaincode with the entrypoint selected at build time: the reflective dispatcher of
+// lib/chaincode by default, or the fabric-contract-api-go contract when built with -tags contractapi.
 func main() {
 	util.ChaincodeName = "fffffefe"
-	err := shim.Start(new(chaincode.ChainCode))
+	err := startChaincode()
 	if err != nil {
 		fmt.Printf("Error starting chaincode: %s", err)
 	}
@@ -0,0 +1,25 @@
+//go:build contractapi
+// +build contractapi
+
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package main
+
+import (
+	"example.com/fffffefe/lib/chaincode"
+	"example.com/fffffefe/src"
+)
+
+func startChaincode() error {
+	if err := src.SelfCheck(); err != nil {
+		return err
+	}
+	contractChaincode, err := chaincode.NewContractChaincode()
+	if err != nil {
+		return err
+	}
+	return contractChaincode.Start()
+}
@@ -0,0 +1,23 @@
+//go:build !contractapi
+// +build !contractapi
+
+/**
+ *
+ * Copyright (c) 2020, Oracle and/or its affiliates. All rights reserved.
+ *
+ */
+package main
+
+import (
+	"example.com/fffffefe/lib/chaincode"
+	"example.com/fffffefe/src"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+func startChaincode() error {
+	// a controller method or asset type a transaction cannot use is a startup failure, not a transaction error
+	if err := src.SelfCheck(); err != nil {
+		return err
+	}
+	return shim.Start(new(chaincode.ChainCode))
+}
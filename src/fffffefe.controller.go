@@ -6,46 +6,204 @@
 package src
 
 import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
 	"example.com/fffffefe/lib/model"
+	"example.com/fffffefe/lib/util"
+	"example.com/fffffefe/lib/util/date"
+	"example.com/fffffefe/lib/util/decimal"
+	"example.com/fffffefe/lib/util/validators"
+	"github.com/creasty/defaults"
 )
 
 type Controller struct {
 }
 
-func (t *Controller) Init() (interface{}, error) {
-	return nil, nil
+// assetTypes lists the types of the assets stored in the ledger. They are registered with the model, which
+// decodes ledger records into them by their AssetType.
+var assetTypes = []interface{}{
+	Customer{}, Retailer{}, Supplier{}, LicenseRenewal{}, Manufacturer{}, Distributor{}, InventoryMovement{},
+	PurchaseOrder{}, Shipment{}, RawMaterialLot{}, ProductBatch{}, Recall{}, Invoice{}, Payment{}, Offer{},
+	OfferRedemption{}, SaleReceipt{},
+}
+
+// participantTypes lists the asset types accepted in the Seed section of the config and the participants
+// which can hold inventory. Bank_details and Account have no id and are only stored within other assets.
+var participantTypes = map[string]bool{
+	"Customer":     true,
+	"Retailer":     true,
+	"Supplier":     true,
+	"Manufacturer": true,
+	"Distributor":  true,
+}
+
+/**
+ *
+ * Init accepts an optional JSON configuration document. It is validated and stored
+ * in the ledger, and the assets listed in its Seed section are created.
+ * Calling Init without a config keeps the existing configuration, e.g. on upgrade.
+ * Once a configuration is stored only an admin may run Init again, and a config without admin MSPs keeps
+ * the stored ones. On the first deploy without admin MSPs, the MSP of the organisation running Init becomes the admin.
+ *
+ */
+func (t *Controller) Init(ctx *util.TxContext, config string) (interface{}, error) {
+	ledger := model.For(ctx)
+	initialised, err := ledger.ConfigExists()
+	if err != nil {
+		return nil, err
+	}
+	if initialised {
+		if err := ledger.CheckAdmin(); err != nil {
+			return nil, err
+		}
+	}
+	stored, err := ledger.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg := stored
+	if config != "" {
+		if cfg, err = model.ParseConfig(config); err != nil {
+			return nil, err
+		}
+	}
+	for assetType := range cfg.Seed {
+		if !participantTypes[assetType] {
+			return nil, fmt.Errorf("Error in parsing config: unknown seed asset type %s", assetType)
+		}
+	}
+	if len(cfg.AdminMSPs) == 0 && initialised {
+		cfg.AdminMSPs = stored.AdminMSPs
+	}
+	if len(cfg.AdminMSPs) == 0 {
+		mspID, err := ctx.CreatorMSPID()
+		if err != nil {
+			return nil, fmt.Errorf("Error in Init: no admin MSP is configured and the creator MSP cannot become admin: %s", err.Error())
+		}
+		cfg.AdminMSPs = []string{mspID}
+	}
+	if err := ledger.SaveConfig(cfg); err != nil {
+		return nil, err
+	}
+	if err := seedAssets(ctx, cfg.Seed); err != nil {
+		return nil, err
+	}
+	cfg.Seed = nil
+	return cfg, nil
+}
+
+// seedAssets creates the seed assets in a deterministic order so that every endorser writes the same set
+func seedAssets(ctx *util.TxContext, seed map[string][]json.RawMessage) error {
+	assetTypes := make([]string, 0, len(seed))
+	for assetType := range seed {
+		assetTypes = append(assetTypes, assetType)
+	}
+	sort.Strings(assetTypes)
+	for _, assetType := range assetTypes {
+		for _, document := range seed[assetType] {
+			asset, err := model.NewAsset(assetType)
+			if err != nil {
+				return fmt.Errorf("Error in seeding %s: %s", assetType, err.Error())
+			}
+			if err := defaults.Set(asset); err != nil {
+				return fmt.Errorf("Error in seeding %s: failure in default setting %s", assetType, err.Error())
+			}
+			if err := json.Unmarshal(document, asset); err != nil {
+				return fmt.Errorf("Error in seeding %s: unmarshalling error %s", assetType, err.Error())
+			}
+			if err := createParticipant(ctx, asset); err != nil {
+				return fmt.Errorf("Error in seeding %s: %s", assetType, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Controller) GetConfig(ctx *util.TxContext) (*model.Config, error) {
+	return model.For(ctx).GetConfig()
+}
+
+// methodDefaults lists the optional parameters of the controller methods with the values they take when omitted,
+// either by name or, for trailing parameters, by position. "" stands for the zero value.
+var methodDefaults = map[string]map[string]string{
+	"Init":                          {"config": ""},
+	"GetLicensesExpiringWithin":     {"days": "30"},
+	"GetSupplierPage":               {"pageSize": "100", "bookmark": ""},
+	"CancelPurchaseOrder":           {"reason": ""},
+	"GetRawMaterialReport":          {"groupBy": "", "filter": ""},
+	"GetManufacturerProductsReport": {"groupBy": "", "filter": ""},
+	"GetDistributionReport":         {"groupBy": "", "filter": ""},
+	"GetRetailSalesReport":          {"groupBy": "", "filter": ""},
+}
+
+func init() {
+	util.RegisterMethods(methodParams, methodDefaults)
+	model.RegisterAssetTypes(assetTypes...)
+}
+
+// GetMetadata describes the transactions of the chaincode, with the names and schemas of their parameters and
+// the schemas of the assets they take and return
+func (t *Controller) GetMetadata() (*util.ChaincodeMetadata, error) {
+	return util.BuildMetadata(t, methodParams)
+}
+
+func (t *Controller) UpdateConfig(ctx *util.TxContext, config string) (interface{}, error) {
+	ledger := model.For(ctx)
+	if err := ledger.CheckAdmin(); err != nil {
+		return nil, err
+	}
+	cfg, err := model.ParseConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Seed) > 0 {
+		return nil, fmt.Errorf("Error in updating config: seed assets can only be given to Init")
+	}
+	if len(cfg.AdminMSPs) == 0 {
+		return nil, fmt.Errorf("Error in updating config: at least one admin MSP is required")
+	}
+	if err := ledger.SaveConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
 //-----------------------------------------------------------------------------
 //Bank_details
 //-----------------------------------------------------------------------------
 
-func (t *Controller) CreateBank_details(asset Bank_details) (interface{}, error) {
-	return model.Save(&asset)
+func (t *Controller) CreateBank_details(ctx *util.TxContext, asset Bank_details) (interface{}, error) {
+	return model.For(ctx).Save(&asset)
 }
 
-func (t *Controller) GetBank_detailsById(id string) (Bank_details, error) {
+func (t *Controller) GetBank_detailsById(ctx *util.TxContext, id string) (Bank_details, error) {
 	var asset Bank_details
-	_, err := model.Get(id, &asset)
+	_, err := model.For(ctx).Get(id, &asset)
 	return asset, err
 }
 
-func (t *Controller) UpdateBank_details(asset Bank_details) (interface{}, error) {
-	return model.Update(&asset)
+func (t *Controller) UpdateBank_details(ctx *util.TxContext, asset Bank_details) (interface{}, error) {
+	return model.For(ctx).Update(&asset)
 }
 
-func (t *Controller) DeleteBank_details(id string) (interface{}, error) {
-	return model.Delete(id)
+func (t *Controller) DeleteBank_details(ctx *util.TxContext, id string) (interface{}, error) {
+	return model.For(ctx).Delete(id)
 }
 
-func (t *Controller) GetBank_detailsHistoryById(id string) (interface{}, error) {
-	historyArray, err := model.GetHistoryByID(id)
+func (t *Controller) GetBank_detailsHistoryById(ctx *util.TxContext, id string) (interface{}, error) {
+	historyArray, err := model.For(ctx).GetHistoryByID(id)
 	return historyArray, err
 }
 
-func (t *Controller) GetBank_detailsByRange(startkey string, endKey string) ([]Bank_details, error) {
+func (t *Controller) GetBank_detailsByRange(ctx *util.TxContext, startkey string, endKey string) ([]Bank_details, error) {
 	var assets []Bank_details
-	_, err := model.GetByRange(startkey, endKey, &assets)
+	_, err := model.For(ctx).GetByRange(startkey, endKey, &assets)
 	return assets, err
 }
 
@@ -53,13 +211,13 @@ func (t *Controller) GetBank_detailsByRange(startkey string, endKey string) ([]B
 //Customer
 //-----------------------------------------------------------------------------
 
-func (t *Controller) CreateCustomer(asset Customer) (interface{}, error) {
-	return model.Save(&asset)
+func (t *Controller) CreateCustomer(ctx *util.TxContext, asset Customer) (interface{}, error) {
+	return model.For(ctx).Save(&asset)
 }
 
-func (t *Controller) GetCustomerById(id string) (Customer, error) {
+func (t *Controller) GetCustomerById(ctx *util.TxContext, id string) (Customer, error) {
 	var asset Customer
-	_, err := model.Get(id, &asset)
+	_, err := model.For(ctx).Get(id, &asset)
 	return asset, err
 }
 
@@ -67,13 +225,16 @@ func (t *Controller) GetCustomerById(id string) (Customer, error) {
 //Retailer
 //-----------------------------------------------------------------------------
 
-func (t *Controller) CreateRetailer(asset Retailer) (interface{}, error) {
-	return model.Save(&asset)
+func (t *Controller) CreateRetailer(ctx *util.TxContext, asset Retailer) (interface{}, error) {
+	if err := claimOwnership(ctx, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, createParticipant(ctx, &asset)
 }
 
-func (t *Controller) GetRetailerById(id string) (Retailer, error) {
+func (t *Controller) GetRetailerById(ctx *util.TxContext, id string) (Retailer, error) {
 	var asset Retailer
-	_, err := model.Get(id, &asset)
+	_, err := model.For(ctx).Get(id, &asset)
 	return asset, err
 }
 
@@ -81,32 +242,32 @@ func (t *Controller) GetRetailerById(id string) (Retailer, error) {
 //Account
 //-----------------------------------------------------------------------------
 
-func (t *Controller) CreateAccount(asset Account) (interface{}, error) {
-	return model.Save(&asset)
+func (t *Controller) CreateAccount(ctx *util.TxContext, asset Account) (interface{}, error) {
+	return model.For(ctx).Save(&asset)
 }
 
-func (t *Controller) GetAccountById(id string) (Account, error) {
+func (t *Controller) GetAccountById(ctx *util.TxContext, id string) (Account, error) {
 	var asset Account
-	_, err := model.Get(id, &asset)
+	_, err := model.For(ctx).Get(id, &asset)
 	return asset, err
 }
 
-func (t *Controller) UpdateAccount(asset Account) (interface{}, error) {
-	return model.Update(&asset)
+func (t *Controller) UpdateAccount(ctx *util.TxContext, asset Account) (interface{}, error) {
+	return model.For(ctx).Update(&asset)
 }
 
-func (t *Controller) DeleteAccount(id string) (interface{}, error) {
-	return model.Delete(id)
+func (t *Controller) DeleteAccount(ctx *util.TxContext, id string) (interface{}, error) {
+	return model.For(ctx).Delete(id)
 }
 
-func (t *Controller) GetAccountHistoryById(id string) (interface{}, error) {
-	historyArray, err := model.GetHistoryByID(id)
+func (t *Controller) GetAccountHistoryById(ctx *util.TxContext, id string) (interface{}, error) {
+	historyArray, err := model.For(ctx).GetHistoryByID(id)
 	return historyArray, err
 }
 
-func (t *Controller) GetAccountByRange(startkey string, endKey string) ([]Account, error) {
+func (t *Controller) GetAccountByRange(ctx *util.TxContext, startkey string, endKey string) ([]Account, error) {
 	var assets []Account
-	_, err := model.GetByRange(startkey, endKey, &assets)
+	_, err := model.For(ctx).GetByRange(startkey, endKey, &assets)
 	return assets, err
 }
 
@@ -114,51 +275,268 @@ func (t *Controller) GetAccountByRange(startkey string, endKey string) ([]Accoun
 //Supplier
 //-----------------------------------------------------------------------------
 
-func (t *Controller) CreateSupplier(asset Supplier) (interface{}, error) {
-	return model.Save(&asset)
+const (
+	SupplierActive    = "active"
+	SupplierSuspended = "suspended"
+	SupplierRevoked   = "revoked"
+)
+
+func adminOnly(ctx *util.TxContext, current interface{}, updated interface{}) error {
+	return model.For(ctx).CheckAdmin()
+}
+
+// ownerOrAdmin lets the organisation owning the participant, or an admin, make the change
+func ownerOrAdmin(ctx *util.TxContext, current interface{}, updated interface{}) error {
+	if admin, err := model.For(ctx).IsAdmin(); err == nil && admin {
+		return nil
+	}
+	return authorizeParty(ctx, current)
 }
 
-func (t *Controller) GetSupplierById(id string) (Supplier, error) {
+func (t *Controller) CreateSupplier(ctx *util.TxContext, asset Supplier) (interface{}, error) {
+	if err := claimOwnership(ctx, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, createParticipant(ctx, &asset)
+}
+
+func (t *Controller) GetSupplierById(ctx *util.TxContext, id string) (Supplier, error) {
 	var asset Supplier
-	_, err := model.Get(id, &asset)
+	_, err := model.For(ctx).Get(id, &asset)
 	return asset, err
 }
 
-func (t *Controller) UpdateSupplier(asset Supplier) (interface{}, error) {
-	return model.Update(&asset)
+// UpdateSupplier lets the organisation owning the supplier, or an admin, change it. The licence can only be
+// changed by an admin or through RenewSupplierLicense.
+func (t *Controller) UpdateSupplier(ctx *util.TxContext, asset Supplier) (interface{}, error) {
+	ledger := model.For(ctx)
+	var current Supplier
+	if _, err := ledger.Get(asset.SupplierId, &current); err != nil {
+		return nil, err
+	}
+	if err := ownerOrAdmin(ctx, &current, &asset); err != nil {
+		return nil, err
+	}
+	if err := checkLicenseUnchanged(ctx, &current, &asset); err != nil {
+		return nil, err
+	}
+	if err := checkParticipantUpdate(ctx, asset.SupplierId, &asset); err != nil {
+		return nil, err
+	}
+	return ledger.Update(&asset)
+}
+
+// checkLicenseUnchanged rejects updates by non admins which change the licence, its expiry or the active flag of
+// the supplier or its account
+func checkLicenseUnchanged(ctx *util.TxContext, current *Supplier, updated *Supplier) error {
+	if admin, err := model.For(ctx).IsAdmin(); err == nil && admin {
+		return nil
+	}
+	if current.License != updated.License || !current.ExpiryDate.Equal(updated.ExpiryDate.Time) || current.Active != updated.Active ||
+		current.Account.License != updated.Account.License || !current.Account.ExpiryDate.Equal(updated.Account.ExpiryDate.Time) ||
+		current.Account.Active != updated.Account.Active {
+		return fmt.Errorf("Error in updating: licence of supplier %s can only be changed through RenewSupplierLicense", current.SupplierId)
+	}
+	return nil
 }
 
-func (t *Controller) DeleteSupplier(id string) (interface{}, error) {
-	return model.Delete(id)
+func (t *Controller) DeleteSupplier(ctx *util.TxContext, id string) (interface{}, error) {
+	ledger := model.For(ctx)
+	var current Supplier
+	if _, err := ledger.Get(id, &current); err != nil {
+		return nil, err
+	}
+	if err := ownerOrAdmin(ctx, &current, nil); err != nil {
+		return nil, err
+	}
+	return ledger.Delete(id)
 }
 
-func (t *Controller) GetSupplierHistoryById(id string) (interface{}, error) {
-	historyArray, err := model.GetHistoryByID(id)
+func (t *Controller) GetSupplierHistoryById(ctx *util.TxContext, id string) (interface{}, error) {
+	historyArray, err := model.For(ctx).GetHistoryByID(id)
 	return historyArray, err
 }
 
-func (t *Controller) GetSupplierByRange(startkey string, endKey string) ([]Supplier, error) {
+func (t *Controller) GetSupplierByRange(ctx *util.TxContext, startkey string, endKey string) ([]Supplier, error) {
 	var assets []Supplier
-	_, err := model.GetByRange(startkey, endKey, &assets)
+	_, err := model.For(ctx).GetByRange(startkey, endKey, &assets)
 	return assets, err
 }
 
+// GetSupplierPage returns up to pageSize suppliers in key order, starting at bookmark, and the bookmark of the next page
+func (t *Controller) GetSupplierPage(ctx *util.TxContext, pageSize int, bookmark util.Bookmark) ([]Supplier, util.Bookmark, error) {
+	if pageSize < 1 || pageSize > model.MaxQueryLimit {
+		return nil, "", fmt.Errorf("Error in getting suppliers: page size must be between 1 and %d, given %d", model.MaxQueryLimit, pageSize)
+	}
+	var assets []Supplier
+	// the largest rune as end key, since an empty one is only open-ended on a peer
+	next, err := model.For(ctx).GetPageByRange(string(bookmark), string(utf8.MaxRune), pageSize, &assets)
+	if err != nil {
+		return nil, "", err
+	}
+	return assets, util.Bookmark(next), nil
+}
+
+const licenseRenewalIndex = "LicenseRenewal~supplier~id"
+
+// checkLicense refuses a participant in a transaction if it is a supplier whose own or account licence
+// is inactive or expired at the transaction timestamp
+func checkLicense(ctx *util.TxContext, asset interface{}) error {
+	supplier, ok := asset.(*Supplier)
+	if !ok {
+		return nil
+	}
+	now, err := transactionTime(ctx)
+	if err != nil {
+		return err
+	}
+	if !supplier.Active || supplier.Status != SupplierActive {
+		return fmt.Errorf("Error in checking licence: supplier %s is not active", supplier.SupplierId)
+	}
+	if supplier.ExpiryDate.IsZero() {
+		return fmt.Errorf("Error in checking licence: supplier %s has no licence expiry date", supplier.SupplierId)
+	}
+	if !supplier.ExpiryDate.After(now) {
+		return fmt.Errorf("Error in checking licence: licence %s of supplier %s expired on %s", supplier.License, supplier.SupplierId, supplier.ExpiryDate.Format(date.CustomDateLayout))
+	}
+	account := supplier.Account
+	if account.License == "" {
+		return nil
+	}
+	if !account.Active {
+		return fmt.Errorf("Error in checking licence: account of supplier %s is not active", supplier.SupplierId)
+	}
+	if !account.ExpiryDate.After(now) {
+		return fmt.Errorf("Error in checking licence: account licence %s of supplier %s expired on %s", account.License, supplier.SupplierId, account.ExpiryDate.Format(date.CustomDateLayout))
+	}
+	return nil
+}
+
+// RenewSupplierLicense replaces the licence of a supplier and reactivates it. Only regulators and admins can renew
+// licences. expiryDate is given as YYYY-MM-DD and must be later than both the current expiry and the transaction.
+func (t *Controller) RenewSupplierLicense(ctx *util.TxContext, supplierId string, license string, expiryDate string) (interface{}, error) {
+	regulator, err := model.For(ctx).IsRegulator()
+	if err != nil {
+		return nil, err
+	}
+	if !regulator {
+		if err := model.For(ctx).CheckAdmin(); err != nil {
+			return nil, err
+		}
+	}
+	expiry, err := time.Parse(date.CustomDateLayout, expiryDate)
+	if err != nil {
+		return nil, fmt.Errorf("Error in renewing licence: expiry date must be given as YYYY-MM-DD, given %s", expiryDate)
+	}
+	now, err := transactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var supplier Supplier
+	if _, err := model.For(ctx).Get(supplierId, &supplier); err != nil {
+		return nil, err
+	}
+	renewal := LicenseRenewal{
+		RenewalId:          model.For(ctx).NextTxScopedId("renewal"),
+		SupplierId:         supplierId,
+		PreviousLicense:    supplier.License,
+		PreviousExpiryDate: supplier.ExpiryDate,
+		License:            license,
+		ExpiryDate:         date.Date{Time: expiry},
+		TxId:               model.For(ctx).GetTransactionId(),
+		Timestamp:          now,
+	}
+	renewal.RenewedBy, _ = ctx.CreatorMSPID()
+	if !renewal.ExpiryDate.After(now) || !renewal.ExpiryDate.After(supplier.ExpiryDate) {
+		return nil, fmt.Errorf("Error in renewing licence: new expiry date %s must be later than the current expiry and the transaction date", expiryDate)
+	}
+
+	supplier.License = license
+	supplier.ExpiryDate = renewal.ExpiryDate
+	supplier.Active = true
+	if _, err := model.For(ctx).Update(&supplier); err != nil {
+		return nil, err
+	}
+	if _, err := model.For(ctx).Save(&renewal); err != nil {
+		return nil, err
+	}
+	if err := model.For(ctx).PutIndexEntry(licenseRenewalIndex, []string{supplierId, renewal.RenewalId}); err != nil {
+		return nil, err
+	}
+	return &renewal, nil
+}
+
+// GetLicenseRenewalsBySupplier returns the licence renewal history of a supplier, oldest first
+func (t *Controller) GetLicenseRenewalsBySupplier(ctx *util.TxContext, supplierId string) ([]LicenseRenewal, error) {
+	ids, err := model.For(ctx).GetIdsByCompositeKey(licenseRenewalIndex, []string{supplierId}, 1)
+	if err != nil {
+		return nil, err
+	}
+	renewals := []LicenseRenewal{}
+	for _, id := range ids {
+		var renewal LicenseRenewal
+		if _, err := model.For(ctx).Get(id, &renewal); err != nil {
+			return nil, err
+		}
+		renewals = append(renewals, renewal)
+	}
+	sort.SliceStable(renewals, func(i, j int) bool {
+		return renewals[i].Timestamp.Before(renewals[j].Timestamp)
+	})
+	return renewals, nil
+}
+
+// GetLicensesExpiringWithin lists the supplier licences which are still valid but expire within the given number of days
+func (t *Controller) GetLicensesExpiringWithin(ctx *util.TxContext, days int) ([]LicenseExpiry, error) {
+	if days < 0 {
+		return nil, fmt.Errorf("Error in getting expiring licences: days must not be negative, given %d", days)
+	}
+	now, err := transactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit := now.AddDate(0, 0, days)
+	var suppliers []Supplier
+	if _, err := model.For(ctx).GetByRange("", "", &suppliers); err != nil {
+		return nil, err
+	}
+	expiring := []LicenseExpiry{}
+	for _, supplier := range suppliers {
+		if !supplier.ExpiryDate.After(now) || supplier.ExpiryDate.Time.After(limit) {
+			continue
+		}
+		expiring = append(expiring, LicenseExpiry{
+			SupplierId: supplier.SupplierId,
+			License:    supplier.License,
+			ExpiryDate: supplier.ExpiryDate,
+			DaysLeft:   int(supplier.ExpiryDate.Sub(now.Time).Hours() / 24),
+		})
+	}
+	sort.SliceStable(expiring, func(i, j int) bool {
+		return expiring[i].ExpiryDate.Before(expiring[j].ExpiryDate)
+	})
+	return expiring, nil
+}
+
 //-----------------------------------------------------------------------------
 //Manufacturer
 //-----------------------------------------------------------------------------
 
-func (t *Controller) CreateManufacturer(asset Manufacturer) (interface{}, error) {
-	return model.Save(&asset)
+func (t *Controller) CreateManufacturer(ctx *util.TxContext, asset Manufacturer) (interface{}, error) {
+	if err := claimOwnership(ctx, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, createParticipant(ctx, &asset)
 }
 
-func (t *Controller) GetManufacturerById(id string) (Manufacturer, error) {
+func (t *Controller) GetManufacturerById(ctx *util.TxContext, id string) (Manufacturer, error) {
 	var asset Manufacturer
-	_, err := model.Get(id, &asset)
+	_, err := model.For(ctx).Get(id, &asset)
 	return asset, err
 }
 
-func (t *Controller) GetManufacturerHistoryById(id string) (interface{}, error) {
-	historyArray, err := model.GetHistoryByID(id)
+func (t *Controller) GetManufacturerHistoryById(ctx *util.TxContext, id string) (interface{}, error) {
+	historyArray, err := model.For(ctx).GetHistoryByID(id)
 	return historyArray, err
 }
 
@@ -166,49 +544,181 @@ func (t *Controller) GetManufacturerHistoryById(id string) (interface{}, error)
 //Distributor
 //-----------------------------------------------------------------------------
 
-func (t *Controller) CreateDistributor(asset Distributor) (interface{}, error) {
-	return model.Save(&asset)
+const (
+	ShipmentPending   = "pending"
+	ShipmentInTransit = "in-transit"
+	ShipmentDelivered = "delivered"
+)
+
+func hasProductsToShip(ctx *util.TxContext, current interface{}, updated interface{}) error {
+	if updated.(*Distributor).ProductsToBeShipped <= 0 {
+		return fmt.Errorf("distributor has no products to ship")
+	}
+	return nil
 }
 
-func (t *Controller) GetDistributorById(id string) (Distributor, error) {
+func (t *Controller) CreateDistributor(ctx *util.TxContext, asset Distributor) (interface{}, error) {
+	if err := claimOwnership(ctx, &asset); err != nil {
+		return nil, err
+	}
+	return &asset, createParticipant(ctx, &asset)
+}
+
+func (t *Controller) GetDistributorById(ctx *util.TxContext, id string) (Distributor, error) {
 	var asset Distributor
-	_, err := model.Get(id, &asset)
+	_, err := model.For(ctx).Get(id, &asset)
 	return asset, err
 }
 
+// UpdateDistributor lets the organisation owning the distributor, or an admin, change it
+func (t *Controller) UpdateDistributor(ctx *util.TxContext, asset Distributor) (interface{}, error) {
+	ledger := model.For(ctx)
+	var current Distributor
+	if _, err := ledger.Get(asset.DistributorId, &current); err != nil {
+		return nil, err
+	}
+	if err := ownerOrAdmin(ctx, &current, &asset); err != nil {
+		return nil, err
+	}
+	if err := checkParticipantUpdate(ctx, asset.DistributorId, &asset); err != nil {
+		return nil, err
+	}
+	return ledger.Update(&asset)
+}
+
 //-----------------------------------------------------------------------------
 //Custom Methods
 //-----------------------------------------------------------------------------
 
+// GetTransitionHistoryById returns the status changes recorded for an asset with a state machine
+func (t *Controller) GetTransitionHistoryById(ctx *util.TxContext, id string) ([]model.TransitionRecord, error) {
+	return model.For(ctx).GetTransitionsByID(id)
+}
+
 /**
  *
  * BDB sql rich queries can be executed in OBP CS/EE.
  * This method can be invoked only when connected to remote OBP CS/EE network.
+ * Raw queries can read any record and run unindexed, so only admins may run them. Other callers use QueryAssets.
  *
  */
-func (t *Controller) ExecuteQuery(inputQuery string) (interface{}, error) {
-	resultArray, err := model.Query(inputQuery)
+func (t *Controller) ExecuteQuery(ctx *util.TxContext, inputQuery string) (interface{}, error) {
+	if err := model.For(ctx).CheckAdmin(); err != nil {
+		return nil, err
+	}
+	resultArray, err := model.For(ctx).Query(inputQuery)
 	return resultArray, err
 }
 
-func (t *Controller) fetchRawMaterial(supplierId string, rawMaterialSupply int) (interface{}, error) {
+// queryableAssets maps the asset types which QueryAssets accepts to their prototypes
+var queryableAssets = map[string]interface{}{
+	"Customer":          Customer{},
+	"Retailer":          Retailer{},
+	"Supplier":          Supplier{},
+	"Manufacturer":      Manufacturer{},
+	"Distributor":       Distributor{},
+	"InventoryMovement": InventoryMovement{},
+	"PurchaseOrder":     PurchaseOrder{},
+	"Shipment":          Shipment{},
+	"RawMaterialLot":    RawMaterialLot{},
+	"ProductBatch":      ProductBatch{},
+	"Recall":            Recall{},
+	"LicenseRenewal":    LicenseRenewal{},
+	"Invoice":           Invoice{},
+	"Payment":           Payment{},
+	"Offer":             Offer{},
+	"SaleReceipt":       SaleReceipt{},
+}
 
-	return nil, nil
+// SelfCheck checks the controller methods and the registered asset types, so that definitions which would fail
+// in a transaction fail at chaincode start instead. The error is a util.Problems listing every problem found.
+func SelfCheck() error {
+	problems := util.CheckController(new(Controller))
+	problems = append(problems, model.CheckAssetTypes()...)
+	names := make([]string, 0, len(participantTypes)+len(queryableAssets))
+	for name := range participantTypes {
+		names = append(names, name)
+	}
+	for name := range queryableAssets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if _, err := model.NewAsset(name); err != nil && (i == 0 || names[i-1] != name) {
+			problems = append(problems, fmt.Sprintf("%s: used by the controller but not a registered asset type", name))
+		}
+	}
+	if len(problems) > 0 {
+		return util.Problems(problems)
+	}
+	return nil
 }
 
-func (t *Controller) getRawMaterialFromSupplier(manufacturerId string, supplierId string, rawMaterialSupply int) (interface{}, error) {
+// WriteCouchIndexes writes the CouchDB index definitions of the queryable assets, derived from their couchIndex tags,
+// into dir. It is run by go generate from the chaincode root.
+func WriteCouchIndexes(dir string) error {
+	names := make([]string, 0, len(queryableAssets))
+	for name := range queryableAssets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	prototypes := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		prototypes = append(prototypes, queryableAssets[name])
+	}
+	return model.WriteCouchIndexes(dir, prototypes...)
+}
 
-	return nil, nil
+// QueryAssets runs a structured query, compiled into a CouchDB selector, over an asset type the caller may query
+func (t *Controller) QueryAssets(ctx *util.TxContext, spec model.QuerySpec) ([]interface{}, error) {
+	ledger := model.For(ctx)
+	prototype, ok := queryableAssets[spec.AssetType]
+	if !ok {
+		return nil, fmt.Errorf("Error in query: unsupported asset type %s", spec.AssetType)
+	}
+	allowed, err := ledger.CanQuery(spec.AssetType)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, fmt.Errorf("Access denied: caller may not query %s", spec.AssetType)
+	}
+	return ledger.QueryAssets(spec, prototype)
 }
 
-func (t *Controller) createProducts(manufacturerId string, rawMaterialConsumed int, productsCreated int) (interface{}, error) {
+// FetchRawMaterial brings raw material from outside the supply chain into the stock of a supplier
+func (t *Controller) FetchRawMaterial(ctx *util.TxContext, supplierId string, rawMaterialSupply int) (interface{}, error) {
+	batch := newInventoryBatch(ctx)
+	if err := batch.move(InventoryRawMaterial, "", supplierId, rawMaterialSupply, "raw material fetched"); err != nil {
+		return nil, err
+	}
+	return batch.commit()
+}
 
-	return nil, nil
+// GetRawMaterialFromSupplier moves raw material from the stock of a supplier to a manufacturer
+func (t *Controller) GetRawMaterialFromSupplier(ctx *util.TxContext, manufacturerId string, supplierId string, rawMaterialSupply int) (interface{}, error) {
+	batch := newInventoryBatch(ctx)
+	if err := batch.move(InventoryRawMaterial, supplierId, manufacturerId, rawMaterialSupply, "raw material supplied"); err != nil {
+		return nil, err
+	}
+	return batch.commit()
 }
 
-func (t *Controller) sendProductsToDistribution() (interface{}, error) {
+// CreateProducts consumes raw material held by a manufacturer and adds the products created to its stock
+func (t *Controller) CreateProducts(ctx *util.TxContext, manufacturerId string, rawMaterialConsumed int, productsCreated int) (interface{}, error) {
+	batch := newInventoryBatch(ctx)
+	if err := batch.move(InventoryRawMaterial, manufacturerId, "", rawMaterialConsumed, "consumed in production"); err != nil {
+		return nil, err
+	}
+	if err := batch.move(InventoryProducts, "", manufacturerId, productsCreated, "produced"); err != nil {
+		return nil, err
+	}
+	return batch.commit()
+}
 
-	return nil, nil
+// SendProductsToDistribution creates a shipment of products held by a manufacturer, bound for a retailer through a distributor
+func (t *Controller) SendProductsToDistribution(ctx *util.TxContext, asset Shipment) (interface{}, error) {
+	return createShipment(ctx, asset)
 }
 
 func (t *Controller) someFunc() (interface{}, error) {
@@ -220,3 +730,2077 @@ func (t *Controller) someFunc2() (interface{}, error) {
 
 	return nil, nil
 }
+
+//-----------------------------------------------------------------------------
+//Inventory
+//-----------------------------------------------------------------------------
+
+const (
+	// InventoryRawMaterial is the inventory item for raw material
+	InventoryRawMaterial = "RawMaterial"
+	// InventoryProducts is the inventory item for finished products
+	InventoryProducts = "Products"
+
+	inventoryMovementIndex = "InventoryMovement~participant~item~id"
+)
+
+var inventoryItems = []string{InventoryRawMaterial, InventoryProducts}
+
+func (t *Controller) GetInventoryMovementById(ctx *util.TxContext, id string) (InventoryMovement, error) {
+	var asset InventoryMovement
+	_, err := model.For(ctx).Get(id, &asset)
+	return asset, err
+}
+
+// GetInventoryMovementsByParticipant returns the movement log of a participant, oldest first
+func (t *Controller) GetInventoryMovementsByParticipant(ctx *util.TxContext, participantId string) ([]InventoryMovement, error) {
+	return movementsOf(ctx, participantId)
+}
+
+// GetInventoryBalance compares the balances recorded on a participant with the balances derived from its movements
+func (t *Controller) GetInventoryBalance(ctx *util.TxContext, participantId string) ([]InventoryBalance, error) {
+	asset, err := loadAsset(ctx, participantId)
+	if err != nil {
+		return nil, err
+	}
+	movements, err := movementsOf(ctx, participantId)
+	if err != nil {
+		return nil, err
+	}
+	var balances []InventoryBalance
+	for _, item := range inventoryItems {
+		recorded, err := inventoryBalanceField(asset, item)
+		if err != nil {
+			continue
+		}
+		derived := 0
+		for _, movement := range movements {
+			if movement.Item != item {
+				continue
+			}
+			if movement.To == participantId {
+				derived += movement.Quantity
+			}
+			if movement.From == participantId {
+				derived -= movement.Quantity
+			}
+		}
+		balances = append(balances, InventoryBalance{
+			ParticipantId: participantId,
+			Item:          item,
+			Recorded:      *recorded,
+			Derived:       derived,
+			Consistent:    *recorded == derived,
+		})
+	}
+	return balances, nil
+}
+
+// inventoryBalanceField returns a pointer to the field holding the balance of item on the given participant
+func inventoryBalanceField(asset interface{}, item string) (*int, error) {
+	switch participant := asset.(type) {
+	case *Supplier:
+		if item == InventoryRawMaterial {
+			return &participant.RawMaterialAvailable, nil
+		}
+	case *Manufacturer:
+		if item == InventoryRawMaterial {
+			return &participant.RawMaterialAvailable, nil
+		}
+		if item == InventoryProducts {
+			return &participant.ProductsAvailable, nil
+		}
+	case *Distributor:
+		if item == InventoryProducts {
+			return &participant.ProductsToBeShipped, nil
+		}
+	case *Retailer:
+		if item == InventoryProducts {
+			return &participant.ProductsAvailable, nil
+		}
+	}
+	return nil, fmt.Errorf("Error in inventory: %T does not hold %s", asset, item)
+}
+
+// loadAsset reads a participant, an asset of one of the participantTypes, from the ledger
+func loadAsset(ctx *util.TxContext, id string) (interface{}, error) {
+	asset, err := model.For(ctx).GetAnyById(id)
+	if err != nil {
+		return nil, err
+	}
+	if name := reflect.TypeOf(asset).Elem().Name(); !participantTypes[name] {
+		return nil, fmt.Errorf("Error in getting: unsupported asset type %s for Id %s", name, id)
+	}
+	return asset, nil
+}
+
+func transactionTime(ctx *util.TxContext) (date.Date, error) {
+	txTime, err := ctx.TxTime()
+	if err != nil {
+		return date.Date{}, err
+	}
+	return date.Date{Time: txTime}, nil
+}
+
+// createParticipant saves a new asset and records an opening movement and lot for every inventory balance it starts with
+func createParticipant(ctx *util.TxContext, asset interface{}) error {
+	opening := make(map[string]int)
+	for _, item := range inventoryItems {
+		balance, err := inventoryBalanceField(asset, item)
+		if err != nil || *balance == 0 {
+			continue
+		}
+		if *balance < 0 {
+			return fmt.Errorf("Error in saving: opening %s balance cannot be negative", item)
+		}
+		opening[item] = *balance
+	}
+	if _, err := model.For(ctx).Save(asset); err != nil {
+		return err
+	}
+	if len(opening) == 0 {
+		return nil
+	}
+	id, err := participantId(asset)
+	if err != nil {
+		return err
+	}
+	batch := newInventoryBatch(ctx)
+	for _, item := range inventoryItems {
+		if quantity, ok := opening[item]; ok {
+			if err := batch.open(item, id, quantity); err != nil {
+				return err
+			}
+		}
+	}
+	_, err = batch.commit()
+	return err
+}
+
+func participantId(asset interface{}) (string, error) {
+	switch participant := asset.(type) {
+	case *Supplier:
+		return participant.SupplierId, nil
+	case *Manufacturer:
+		return participant.ManufacturerId, nil
+	case *Distributor:
+		return participant.DistributorId, nil
+	case *Retailer:
+		return participant.RetailerId, nil
+	}
+	return "", fmt.Errorf("Error in inventory: %T is not an inventory participant", asset)
+}
+
+// checkParticipantUpdate rejects updates which hand the participant over to another organisation or which
+// overwrite inventory balances instead of recording movements
+func checkParticipantUpdate(ctx *util.TxContext, id string, asset interface{}) error {
+	existing, err := loadAsset(ctx, id)
+	if err != nil {
+		return fmt.Errorf("Error in updating: %s", err.Error())
+	}
+	if ownerMSP(existing) != ownerMSP(asset) {
+		return fmt.Errorf("Error in updating: OwnerMSP of %s cannot be changed", id)
+	}
+	for _, item := range inventoryItems {
+		current, err := inventoryBalanceField(existing, item)
+		if err != nil {
+			continue
+		}
+		updated, err := inventoryBalanceField(asset, item)
+		if err != nil {
+			continue
+		}
+		if *current != *updated {
+			return fmt.Errorf("Error in updating: %s balance of %s can only be changed through inventory movements", item, id)
+		}
+	}
+	return nil
+}
+
+// inventoryBatch collects the movements of one transaction. Every participant and lot is read once and
+// written once on commit, since the ledger does not return the transaction's own pending writes.
+type inventoryBatch struct {
+	ctx       *util.TxContext
+	assets    map[string]interface{}
+	order     []string
+	movements []*InventoryMovement
+	lots      map[string]trackedLot
+	lotOrder  []string
+	newLots   map[string]bool
+	// consumed holds the raw material lots consumed by each participant, the sources of the batches it produces
+	consumed map[string][]LotQuantity
+	// indexes holds the index entries to write (true) or delete (false) on commit
+	indexes map[string]bool
+}
+
+func newInventoryBatch(ctx *util.TxContext) *inventoryBatch {
+	return &inventoryBatch{
+		ctx:      ctx,
+		assets:   make(map[string]interface{}),
+		lots:     make(map[string]trackedLot),
+		newLots:  make(map[string]bool),
+		consumed: make(map[string][]LotQuantity),
+		indexes:  make(map[string]bool),
+	}
+}
+
+// participant returns the batch's copy of the asset with the given id, reading it from the ledger on first use
+func (b *inventoryBatch) participant(id string) (interface{}, error) {
+	if asset, ok := b.assets[id]; ok {
+		return asset, nil
+	}
+	asset, err := loadAsset(b.ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	b.assets[id] = asset
+	return asset, nil
+}
+
+// modify returns the batch's copy of the asset with the given id and marks it to be written on commit
+func (b *inventoryBatch) modify(id string) (interface{}, error) {
+	asset, err := b.participant(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, modified := range b.order {
+		if modified == id {
+			return asset, nil
+		}
+	}
+	b.order = append(b.order, id)
+	return asset, nil
+}
+
+// move transfers quantity units of item between two participants. An empty from or to
+// stands for a source or sink outside the supply chain, e.g. production or consumption.
+func (b *inventoryBatch) move(item string, from string, to string, quantity int, reason string) error {
+	return b.moveLots(item, from, to, quantity, nil, reason)
+}
+
+// moveLots is move taking the given lots rather than the oldest ones held, e.g. for goods travelling together
+func (b *inventoryBatch) moveLots(item string, from string, to string, quantity int, pinned []LotQuantity, reason string) error {
+	if quantity <= 0 {
+		return fmt.Errorf("Error in moving inventory: quantity must be positive, given %d", quantity)
+	}
+	if from == to {
+		return fmt.Errorf("Error in moving inventory: source and destination are the same")
+	}
+	available := 0
+	if from != "" {
+		asset, err := b.modify(from)
+		if err != nil {
+			return err
+		}
+		if err := checkLicense(b.ctx, asset); err != nil {
+			return err
+		}
+		balance, err := inventoryBalanceField(asset, item)
+		if err != nil {
+			return err
+		}
+		if *balance < quantity {
+			return fmt.Errorf("Error in moving inventory: %s holds %d %s, cannot move %d", from, *balance, item, quantity)
+		}
+		available = *balance
+		*balance -= quantity
+	}
+	if to != "" {
+		asset, err := b.modify(to)
+		if err != nil {
+			return err
+		}
+		if err := checkLicense(b.ctx, asset); err != nil {
+			return err
+		}
+		balance, err := inventoryBalanceField(asset, item)
+		if err != nil {
+			return err
+		}
+		*balance += quantity
+	}
+	lots, err := b.transferLots(item, from, to, quantity, pinned, available)
+	if err != nil {
+		return err
+	}
+	b.movements = append(b.movements, &InventoryMovement{Item: item, From: from, To: to, Quantity: quantity, Reason: reason, Lots: lots})
+	return nil
+}
+
+// open records the opening balance of a participant, which is already set on the participant itself
+func (b *inventoryBatch) open(item string, to string, quantity int) error {
+	lots, err := b.transferLots(item, "", to, quantity, nil, 0)
+	if err != nil {
+		return err
+	}
+	b.movements = append(b.movements, &InventoryMovement{Item: item, To: to, Quantity: quantity, Reason: "opening balance", Lots: lots})
+	return nil
+}
+
+// transferLots moves holdings of lots, the pinned ones or else the oldest first, from one holder to another.
+// Products or raw material entering the supply chain form a new lot; products made from consumed raw material
+// record it as their sources. Lots under recall cannot move. available is the balance of the holder before the move.
+func (b *inventoryBatch) transferLots(item string, from string, to string, quantity int, pinned []LotQuantity, available int) ([]LotQuantity, error) {
+	if from == "" {
+		lot := b.newLot(item, to, quantity)
+		return []LotQuantity{{LotId: lot.lotId(), Origin: lot.origin(), Quantity: quantity}}, nil
+	}
+	allHeld, err := b.heldLots(item, from)
+	if err != nil {
+		return nil, err
+	}
+	trackedHeld, blocked := 0, 0
+	for _, lot := range allHeld {
+		trackedHeld += lot.holdings()[from]
+		if lot.recalled() {
+			blocked += lot.holdings()[from]
+		}
+	}
+	var allocations []LotQuantity
+	remaining := quantity
+	var held []trackedLot
+	limits := make(map[string]int)
+	if pinned != nil {
+		for _, allocation := range pinned {
+			if allocation.LotId == untrackedLot {
+				continue
+			}
+			lot, err := b.lot(item, allocation.LotId)
+			if err != nil {
+				return nil, err
+			}
+			if lot.recalled() {
+				return nil, fmt.Errorf("Error in moving inventory: lot %s is under recall %s", allocation.LotId, strings.Join(lot.recalls(), ", "))
+			}
+			if lot.holdings()[from] < allocation.Quantity {
+				return nil, fmt.Errorf("Error in moving inventory: %s holds %d of lot %s, cannot move %d", from, lot.holdings()[from], allocation.LotId, allocation.Quantity)
+			}
+			held = append(held, lot)
+			limits[allocation.LotId] += allocation.Quantity
+		}
+	} else {
+		for _, lot := range allHeld {
+			if !lot.recalled() {
+				held = append(held, lot)
+			}
+		}
+	}
+	for _, lot := range held {
+		if remaining == 0 {
+			break
+		}
+		holdings := lot.holdings()
+		take := holdings[from]
+		if limit, ok := limits[lot.lotId()]; ok && take > limit {
+			take = limit
+		}
+		if take > remaining {
+			take = remaining
+		}
+		holdings[from] -= take
+		if holdings[from] == 0 {
+			delete(holdings, from)
+			b.indexes[indexEntry(lotIndex, from, item, lot.lotId())] = false
+		}
+		if to != "" {
+			holdings[to] += take
+			b.indexes[indexEntry(lotIndex, to, item, lot.lotId())] = true
+		}
+		b.markLot(lot.lotId())
+		allocations = append(allocations, LotQuantity{LotId: lot.lotId(), Origin: lot.origin(), Quantity: take})
+		remaining -= take
+	}
+	// balances which predate lot tracking have no lots
+	if remaining > 0 {
+		if remaining > available-trackedHeld {
+			if blocked > 0 {
+				return nil, fmt.Errorf("Error in moving inventory: %d %s held by %s is blocked by a recall", blocked, item, from)
+			}
+			return nil, fmt.Errorf("Error in moving inventory: %s does not hold enough tracked %s", from, item)
+		}
+		allocations = append(allocations, LotQuantity{LotId: untrackedLot, Quantity: remaining})
+	}
+	if to == "" && item == InventoryRawMaterial {
+		b.consumed[from] = append(b.consumed[from], allocations...)
+	}
+	return allocations, nil
+}
+
+func (b *inventoryBatch) newLot(item string, holder string, quantity int) trackedLot {
+	ledger := model.For(b.ctx)
+	txID := ledger.GetTransactionId()
+	var lot trackedLot
+	if item == InventoryRawMaterial {
+		lot = &RawMaterialLot{
+			LotId:    ledger.NextTxScopedId("LOT"),
+			Supplier: holder,
+			Quantity: quantity,
+			Holdings: map[string]int{holder: quantity},
+			TxId:     txID,
+		}
+	} else {
+		batch := &ProductBatch{
+			BatchId:      ledger.NextTxScopedId("BATCH"),
+			Manufacturer: holder,
+			Quantity:     quantity,
+			Sources:      b.consumed[holder],
+			Holdings:     map[string]int{holder: quantity},
+			Sales:        []BatchSale{},
+			TxId:         txID,
+		}
+		delete(b.consumed, holder)
+		for _, source := range batch.Sources {
+			if source.LotId != untrackedLot {
+				b.indexes[indexEntry(batchSourceIndex, source.LotId, batch.BatchId)] = true
+			}
+		}
+		lot = batch
+	}
+	b.lots[lot.lotId()] = lot
+	b.newLots[lot.lotId()] = true
+	b.markLot(lot.lotId())
+	b.indexes[indexEntry(lotIndex, holder, item, lot.lotId())] = true
+	return lot
+}
+
+// heldLots returns the lots of item held by a participant, oldest first
+func (b *inventoryBatch) heldLots(item string, holder string) ([]trackedLot, error) {
+	ids, err := model.For(b.ctx).GetIdsByCompositeKey(lotIndex, []string{holder, item}, 2)
+	if err != nil {
+		return nil, err
+	}
+	for id, lot := range b.lots {
+		if lot.item() == item {
+			ids = append(ids, id)
+		}
+	}
+	var held []trackedLot
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		lot, err := b.lot(item, id)
+		if err != nil {
+			return nil, err
+		}
+		if lot.holdings()[holder] > 0 {
+			held = append(held, lot)
+		}
+	}
+	sort.SliceStable(held, func(i, j int) bool {
+		if !held[i].created().Equal(held[j].created().Time) {
+			return held[i].created().Before(held[j].created())
+		}
+		return held[i].lotId() < held[j].lotId()
+	})
+	return held, nil
+}
+
+// lot returns the batch's copy of a lot, reading it from the ledger on first use
+func (b *inventoryBatch) lot(item string, id string) (trackedLot, error) {
+	if lot, ok := b.lots[id]; ok {
+		return lot, nil
+	}
+	var lot trackedLot = &ProductBatch{}
+	if item == InventoryRawMaterial {
+		lot = &RawMaterialLot{}
+	}
+	if _, err := model.For(b.ctx).Get(id, lot); err != nil {
+		return nil, err
+	}
+	b.lots[id] = lot
+	return lot, nil
+}
+
+func (b *inventoryBatch) markLot(id string) {
+	for _, marked := range b.lotOrder {
+		if marked == id {
+			return
+		}
+	}
+	b.lotOrder = append(b.lotOrder, id)
+}
+
+// sell moves products from a retailer to a customer, recording the sale on the product batches sold
+func (b *inventoryBatch) sell(retailerId string, customerId string, quantity int, reason string) error {
+	if err := b.move(InventoryProducts, retailerId, "", quantity, reason); err != nil {
+		return err
+	}
+	timestamp, err := transactionTime(b.ctx)
+	if err != nil {
+		return err
+	}
+	for _, allocation := range b.movements[len(b.movements)-1].Lots {
+		if allocation.LotId == untrackedLot {
+			continue
+		}
+		batch := b.lots[allocation.LotId].(*ProductBatch)
+		batch.Sales = append(batch.Sales, BatchSale{
+			Retailer:  retailerId,
+			Customer:  customerId,
+			Quantity:  allocation.Quantity,
+			TxId:      model.For(b.ctx).GetTransactionId(),
+			Timestamp: timestamp,
+		})
+		b.indexes[indexEntry(batchSaleIndex, customerId, batch.BatchId)] = true
+	}
+	return nil
+}
+
+// commit writes the modified participants, lots, index entries and the movements to the ledger
+func (b *inventoryBatch) commit() ([]InventoryMovement, error) {
+	ledger := model.For(b.ctx)
+	for _, id := range b.order {
+		if _, err := ledger.Update(b.assets[id]); err != nil {
+			return nil, err
+		}
+	}
+	timestamp, err := transactionTime(b.ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range b.lotOrder {
+		lot := b.lots[id]
+		if b.newLots[id] {
+			lot.setCreated(timestamp)
+			if _, err := ledger.Save(lot); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if _, err := ledger.Update(lot); err != nil {
+			return nil, err
+		}
+	}
+	entries := make([]string, 0, len(b.indexes))
+	for entry := range b.indexes {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	for _, entry := range entries {
+		parts := strings.Split(entry, "\x00")
+		if b.indexes[entry] {
+			err = ledger.PutIndexEntry(parts[0], parts[1:])
+		} else {
+			err = ledger.DelIndexEntry(parts[0], parts[1:])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return saveMovements(b.ctx, b.movements)
+}
+
+func indexEntry(indexName string, attributes ...string) string {
+	return strings.Join(append([]string{indexName}, attributes...), "\x00")
+}
+
+// saveMovements assigns ids to the movements and writes them with their participant index entries
+func saveMovements(ctx *util.TxContext, movements []*InventoryMovement) ([]InventoryMovement, error) {
+	ledger := model.For(ctx)
+	timestamp, err := transactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	txID := ledger.GetTransactionId()
+	result := make([]InventoryMovement, 0, len(movements))
+	for _, movement := range movements {
+		movement.MovementId = ledger.NextTxScopedId("MOV")
+		movement.TxId = txID
+		movement.Timestamp = timestamp
+		if _, err := ledger.Save(movement); err != nil {
+			return nil, err
+		}
+		for _, participant := range []string{movement.From, movement.To} {
+			if participant == "" {
+				continue
+			}
+			if err := ledger.PutIndexEntry(inventoryMovementIndex, []string{participant, movement.Item, movement.MovementId}); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, *movement)
+	}
+	return result, nil
+}
+
+func movementsOf(ctx *util.TxContext, participantId string) ([]InventoryMovement, error) {
+	ids, err := model.For(ctx).GetIdsByCompositeKey(inventoryMovementIndex, []string{participantId}, 2)
+	if err != nil {
+		return nil, err
+	}
+	var movements []InventoryMovement
+	for _, id := range ids {
+		var movement InventoryMovement
+		if _, err := model.For(ctx).Get(id, &movement); err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+	sort.SliceStable(movements, func(i, j int) bool {
+		if !movements[i].Timestamp.Equal(movements[j].Timestamp.Time) {
+			return movements[i].Timestamp.Before(movements[j].Timestamp)
+		}
+		return movements[i].MovementId < movements[j].MovementId
+	})
+	return movements, nil
+}
+
+//-----------------------------------------------------------------------------
+//PurchaseOrder
+//-----------------------------------------------------------------------------
+
+const (
+	PurchaseOrderDraft     = "draft"
+	PurchaseOrderSubmitted = "submitted"
+	PurchaseOrderAccepted  = "accepted"
+	PurchaseOrderShipped   = "shipped"
+	PurchaseOrderReceived  = "received"
+	PurchaseOrderClosed    = "closed"
+	PurchaseOrderCancelled = "cancelled"
+
+	partyBuyer  = "buyer"
+	partySeller = "seller"
+)
+
+// purchaseOrderParty returns a guard letting only the given parties of the order perform a transition
+func purchaseOrderParty(parties ...string) model.Guard {
+	return func(ctx *util.TxContext, current interface{}, updated interface{}) error {
+		order := updated.(*PurchaseOrder)
+		var denied error
+		for _, party := range parties {
+			partyId := order.Buyer
+			if party == partySeller {
+				partyId = order.Seller
+			}
+			participant, err := loadAsset(ctx, partyId)
+			if err != nil {
+				return err
+			}
+			if denied = authorizeParty(ctx, participant); denied == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("only the %s may do this. %s", strings.Join(parties, " or "), denied.Error())
+	}
+}
+
+// CreatePurchaseOrder creates a draft order. Retailers order from distributors and distributors from manufacturers.
+func (t *Controller) CreatePurchaseOrder(ctx *util.TxContext, asset PurchaseOrder) (interface{}, error) {
+	buyer, err := loadAsset(ctx, asset.Buyer)
+	if err != nil {
+		return nil, err
+	}
+	seller, err := loadAsset(ctx, asset.Seller)
+	if err != nil {
+		return nil, err
+	}
+	switch buyer.(type) {
+	case *Retailer:
+		_, ok := seller.(*Distributor)
+		if !ok {
+			return nil, fmt.Errorf("Error in saving purchase order: retailer %s can only order from a distributor", asset.Buyer)
+		}
+	case *Distributor:
+		_, ok := seller.(*Manufacturer)
+		if !ok {
+			return nil, fmt.Errorf("Error in saving purchase order: distributor %s can only order from a manufacturer", asset.Buyer)
+		}
+	default:
+		return nil, fmt.Errorf("Error in saving purchase order: %s cannot place orders", asset.Buyer)
+	}
+	if err := authorizeParty(ctx, buyer); err != nil {
+		return nil, err
+	}
+	asset.Status = ""
+	asset.CancelReason = ""
+	asset.History = []PurchaseOrderTransition{}
+	return model.For(ctx).Save(&asset)
+}
+
+func (t *Controller) GetPurchaseOrderById(ctx *util.TxContext, id string) (PurchaseOrder, error) {
+	var asset PurchaseOrder
+	_, err := model.For(ctx).Get(id, &asset)
+	return asset, err
+}
+
+// SubmitPurchaseOrder sends a draft order to the seller and adds it to the retailer's ordered products
+func (t *Controller) SubmitPurchaseOrder(ctx *util.TxContext, id string) (interface{}, error) {
+	return transitionPurchaseOrder(ctx, id, PurchaseOrderSubmitted, func(order *PurchaseOrder, from string, batch *inventoryBatch) error {
+		return adjustProductsOrdered(batch, order.Buyer, order.quantity())
+	})
+}
+
+func (t *Controller) AcceptPurchaseOrder(ctx *util.TxContext, id string) (interface{}, error) {
+	return transitionPurchaseOrder(ctx, id, PurchaseOrderAccepted, nil)
+}
+
+// ShipPurchaseOrder moves the ordered products from the seller to the buyer
+func (t *Controller) ShipPurchaseOrder(ctx *util.TxContext, id string) (interface{}, error) {
+	return transitionPurchaseOrder(ctx, id, PurchaseOrderShipped, func(order *PurchaseOrder, from string, batch *inventoryBatch) error {
+		if err := batch.move(InventoryProducts, order.Seller, order.Buyer, order.quantity(), "shipped on purchase order "+order.PurchaseOrderId); err != nil {
+			return err
+		}
+		seller, err := batch.modify(order.Seller)
+		if err != nil {
+			return err
+		}
+		if distributor, ok := seller.(*Distributor); ok {
+			distributor.ProductsShipped += order.quantity()
+		}
+		return nil
+	})
+}
+
+// ReceivePurchaseOrder confirms the delivery, fulfilling the retailer's ordered products
+func (t *Controller) ReceivePurchaseOrder(ctx *util.TxContext, id string) (interface{}, error) {
+	return transitionPurchaseOrder(ctx, id, PurchaseOrderReceived, func(order *PurchaseOrder, from string, batch *inventoryBatch) error {
+		buyer, err := batch.modify(order.Buyer)
+		if err != nil {
+			return err
+		}
+		if distributor, ok := buyer.(*Distributor); ok {
+			distributor.ProductsReceived += order.quantity()
+		}
+		return adjustProductsOrdered(batch, order.Buyer, -order.quantity())
+	})
+}
+
+func (t *Controller) ClosePurchaseOrder(ctx *util.TxContext, id string) (interface{}, error) {
+	return transitionPurchaseOrder(ctx, id, PurchaseOrderClosed, nil)
+}
+
+// CancelPurchaseOrder cancels an order which has not been shipped yet
+func (t *Controller) CancelPurchaseOrder(ctx *util.TxContext, id string, reason string) (interface{}, error) {
+	return transitionPurchaseOrder(ctx, id, PurchaseOrderCancelled, func(order *PurchaseOrder, from string, batch *inventoryBatch) error {
+		order.CancelReason = reason
+		if from == PurchaseOrderDraft {
+			return nil
+		}
+		return adjustProductsOrdered(batch, order.Buyer, -order.quantity())
+	})
+}
+
+func (order *PurchaseOrder) quantity() int {
+	total := 0
+	for _, line := range order.Lines {
+		total += line.Quantity
+	}
+	return total
+}
+
+func adjustProductsOrdered(batch *inventoryBatch, buyerId string, quantity int) error {
+	buyer, err := batch.participant(buyerId)
+	if err != nil {
+		return err
+	}
+	if _, ok := buyer.(*Retailer); !ok {
+		return nil
+	}
+	buyer, err = batch.modify(buyerId)
+	if err != nil {
+		return err
+	}
+	buyer.(*Retailer).ProductsOrdered += quantity
+	return nil
+}
+
+// transitionPurchaseOrder moves the order to the given status, which its state machine checks against the current
+// status and the calling party. apply performs the side effects on the parties, given the previous status.
+func transitionPurchaseOrder(ctx *util.TxContext, id string, to string, apply func(order *PurchaseOrder, from string, batch *inventoryBatch) error) (interface{}, error) {
+	ledger := model.For(ctx)
+	var order PurchaseOrder
+	if _, err := ledger.Get(id, &order); err != nil {
+		return nil, err
+	}
+	from := order.Status
+	order.Status = to
+	if err := ledger.CheckTransition(&order); err != nil {
+		return nil, err
+	}
+
+	batch := newInventoryBatch(ctx)
+	if apply != nil {
+		if err := apply(&order, from, batch); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := batch.commit(); err != nil {
+		return nil, err
+	}
+	timestamp, err := transactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	callerMSP, _ := ctx.CreatorMSPID()
+	order.History = append(order.History, PurchaseOrderTransition{
+		From:      from,
+		To:        to,
+		By:        callerMSP,
+		TxId:      ledger.GetTransactionId(),
+		Timestamp: timestamp,
+	})
+	return ledger.Update(&order)
+}
+
+// ownerMSP returns the MSP id of the organisation owning a participant
+func ownerMSP(participant interface{}) string {
+	if owner := ownerField(participant); owner != nil {
+		return *owner
+	}
+	return ""
+}
+
+// ownerField returns a pointer to the OwnerMSP field of a participant, or nil for assets without an owner
+func ownerField(participant interface{}) *string {
+	switch p := participant.(type) {
+	case *Supplier:
+		return &p.OwnerMSP
+	case *Manufacturer:
+		return &p.OwnerMSP
+	case *Distributor:
+		return &p.OwnerMSP
+	case *Retailer:
+		return &p.OwnerMSP
+	}
+	return nil
+}
+
+// claimOwnership makes the organisation creating a participant its owner. Participants cannot be created on
+// behalf of another organisation.
+func claimOwnership(ctx *util.TxContext, participant interface{}) error {
+	owner := ownerField(participant)
+	if owner == nil {
+		return nil
+	}
+	callerMSP, err := ctx.CreatorMSPID()
+	if err != nil {
+		return fmt.Errorf("Access denied: %s", err.Error())
+	}
+	if *owner != "" && *owner != callerMSP {
+		return fmt.Errorf("Access denied: caller from %s cannot create %T owned by %s", callerMSP, participant, *owner)
+	}
+	*owner = callerMSP
+	return nil
+}
+
+// authorizeParty returns an error unless the caller belongs to the organisation owning the participant.
+// Admins may act on behalf of participants which have no owner.
+func authorizeParty(ctx *util.TxContext, participant interface{}) error {
+	callerMSP, err := ctx.CreatorMSPID()
+	if err != nil {
+		return fmt.Errorf("Access denied: %s", err.Error())
+	}
+	owner := ownerMSP(participant)
+	if owner != "" && owner == callerMSP {
+		return nil
+	}
+	if owner == "" {
+		if admin, err := model.For(ctx).IsAdmin(); err == nil && admin {
+			return nil
+		}
+	}
+	return fmt.Errorf("Access denied: caller from %s does not own %T", callerMSP, participant)
+}
+
+//-----------------------------------------------------------------------------
+//Shipment
+//-----------------------------------------------------------------------------
+
+const (
+	ShipmentAtManufacturer = "at-manufacturer"
+	ShipmentAtDistributor  = "at-distributor"
+	ShipmentAtRetailer     = "at-retailer"
+
+	shipmentIndex = "Shipment~participant~id"
+)
+
+// signedByCustodian lets a shipment change hands only in a transaction submitted by the new custodian's organisation
+func signedByCustodian(ctx *util.TxContext, current interface{}, updated interface{}) error {
+	receiver, err := loadAsset(ctx, updated.(*Shipment).Custodian)
+	if err != nil {
+		return err
+	}
+	return authorizeParty(ctx, receiver)
+}
+
+func (t *Controller) GetShipmentById(ctx *util.TxContext, id string) (Shipment, error) {
+	var asset Shipment
+	_, err := model.For(ctx).Get(id, &asset)
+	return asset, err
+}
+
+// ReceiveShipment hands a shipment over to the next participant of the chain. It must be submitted by the receiving organisation.
+func (t *Controller) ReceiveShipment(ctx *util.TxContext, shipmentId string) (interface{}, error) {
+	var shipment Shipment
+	if _, err := model.For(ctx).Get(shipmentId, &shipment); err != nil {
+		return nil, err
+	}
+	from := shipment.Custodian
+	switch shipment.Status {
+	case ShipmentAtManufacturer:
+		shipment.Custodian = shipment.Distributor
+		shipment.Status = ShipmentAtDistributor
+	case ShipmentAtDistributor:
+		shipment.Custodian = shipment.Retailer
+		shipment.Status = ShipmentAtRetailer
+	default:
+		return nil, fmt.Errorf("Error in receiving shipment %s: shipment has already been delivered", shipmentId)
+	}
+	if err := model.For(ctx).CheckTransition(&shipment); err != nil {
+		return nil, err
+	}
+
+	batch := newInventoryBatch(ctx)
+	if err := batch.moveLots(InventoryProducts, from, shipment.Custodian, shipment.Quantity, shipment.Lots, "shipment "+shipmentId); err != nil {
+		return nil, err
+	}
+	// the goods picked at the first handover travel together through the rest of the chain
+	if shipment.Lots == nil {
+		shipment.Lots = batch.movements[len(batch.movements)-1].Lots
+	}
+	for _, id := range []string{from, shipment.Custodian} {
+		participant, err := batch.modify(id)
+		if err != nil {
+			return nil, err
+		}
+		if distributor, ok := participant.(*Distributor); ok {
+			if id == from {
+				distributor.ProductsShipped += shipment.Quantity
+			} else {
+				distributor.ProductsReceived += shipment.Quantity
+			}
+		}
+	}
+	if _, err := batch.commit(); err != nil {
+		return nil, err
+	}
+	transfer, err := custodyTransfer(ctx, from, shipment.Custodian, shipment.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	shipment.CustodyChain = append(shipment.CustodyChain, transfer)
+	return model.For(ctx).Update(&shipment)
+}
+
+// GetShipmentCustodyChain returns the custody transfers of a shipment, starting with its creation
+func (t *Controller) GetShipmentCustodyChain(ctx *util.TxContext, shipmentId string) ([]CustodyTransfer, error) {
+	var shipment Shipment
+	if _, err := model.For(ctx).Get(shipmentId, &shipment); err != nil {
+		return nil, err
+	}
+	return shipment.CustodyChain, nil
+}
+
+// GetShipmentsByDistributor returns every shipment routed through a distributor, with its custody chain
+func (t *Controller) GetShipmentsByDistributor(ctx *util.TxContext, distributorId string) ([]Shipment, error) {
+	return shipmentsOf(ctx, distributorId)
+}
+
+func createShipment(ctx *util.TxContext, asset Shipment) (interface{}, error) {
+	manufacturer, err := loadAsset(ctx, asset.Manufacturer)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := manufacturer.(*Manufacturer); !ok {
+		return nil, fmt.Errorf("Error in saving shipment: %s is not a manufacturer", asset.Manufacturer)
+	}
+	distributor, err := loadAsset(ctx, asset.Distributor)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := distributor.(*Distributor); !ok {
+		return nil, fmt.Errorf("Error in saving shipment: %s is not a distributor", asset.Distributor)
+	}
+	retailer, err := loadAsset(ctx, asset.Retailer)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := retailer.(*Retailer); !ok {
+		return nil, fmt.Errorf("Error in saving shipment: %s is not a retailer", asset.Retailer)
+	}
+	if err := authorizeParty(ctx, manufacturer); err != nil {
+		return nil, err
+	}
+	transfer, err := custodyTransfer(ctx, "", asset.Manufacturer, asset.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	asset.Status = ""
+	asset.Custodian = asset.Manufacturer
+	asset.Lots = nil
+	asset.CustodyChain = []CustodyTransfer{transfer}
+	if _, err := model.For(ctx).Save(&asset); err != nil {
+		return nil, err
+	}
+	for _, participantId := range []string{asset.Manufacturer, asset.Distributor, asset.Retailer} {
+		if err := model.For(ctx).PutIndexEntry(shipmentIndex, []string{participantId, asset.ShipmentId}); err != nil {
+			return nil, err
+		}
+	}
+	return &asset, nil
+}
+
+func custodyTransfer(ctx *util.TxContext, from string, to string, quantity int) (CustodyTransfer, error) {
+	timestamp, err := transactionTime(ctx)
+	if err != nil {
+		return CustodyTransfer{}, err
+	}
+	signedBy, _ := ctx.CreatorMSPID()
+	return CustodyTransfer{
+		From:      from,
+		To:        to,
+		Quantity:  quantity,
+		SignedBy:  signedBy,
+		TxId:      model.For(ctx).GetTransactionId(),
+		Timestamp: timestamp,
+	}, nil
+}
+
+func shipmentsOf(ctx *util.TxContext, participantId string) ([]Shipment, error) {
+	ids, err := model.For(ctx).GetIdsByCompositeKey(shipmentIndex, []string{participantId}, 1)
+	if err != nil {
+		return nil, err
+	}
+	shipments := []Shipment{}
+	for _, id := range ids {
+		var shipment Shipment
+		if _, err := model.For(ctx).Get(id, &shipment); err != nil {
+			return nil, err
+		}
+		shipments = append(shipments, shipment)
+	}
+	return shipments, nil
+}
+
+//-----------------------------------------------------------------------------
+//Traceability
+//-----------------------------------------------------------------------------
+
+const (
+	lotIndex         = "Lot~holder~item~id"
+	batchSourceIndex = "ProductBatch~sourceLot~id"
+	batchSaleIndex   = "ProductBatch~customer~id"
+
+	// untrackedLot stands for quantities which were in stock before lots were tracked
+	untrackedLot = "untracked"
+)
+
+// trackedLot is implemented by RawMaterialLot and ProductBatch
+type trackedLot interface {
+	lotId() string
+	item() string
+	origin() string
+	created() date.Date
+	setCreated(created date.Date)
+	holdings() map[string]int
+	recalls() []string
+	recalled() bool
+	flagRecall(recallId string)
+}
+
+func (lot *RawMaterialLot) lotId() string  { return lot.LotId }
+func (lot *RawMaterialLot) item() string   { return InventoryRawMaterial }
+func (lot *RawMaterialLot) origin() string { return lot.Supplier }
+
+func (lot *RawMaterialLot) created() date.Date { return lot.Created }
+
+func (lot *RawMaterialLot) setCreated(created date.Date) { lot.Created = created }
+
+func (lot *RawMaterialLot) holdings() map[string]int {
+	if lot.Holdings == nil {
+		lot.Holdings = make(map[string]int)
+	}
+	return lot.Holdings
+}
+
+func (lot *RawMaterialLot) recalls() []string { return lot.RecallIds }
+func (lot *RawMaterialLot) recalled() bool    { return len(lot.RecallIds) > 0 }
+
+func (lot *RawMaterialLot) flagRecall(recallId string) {
+	lot.RecallIds = append(lot.RecallIds, recallId)
+}
+
+func (batch *ProductBatch) lotId() string  { return batch.BatchId }
+func (batch *ProductBatch) item() string   { return InventoryProducts }
+func (batch *ProductBatch) origin() string { return batch.Manufacturer }
+
+func (batch *ProductBatch) created() date.Date { return batch.Created }
+
+func (batch *ProductBatch) setCreated(created date.Date) { batch.Created = created }
+
+func (batch *ProductBatch) holdings() map[string]int {
+	if batch.Holdings == nil {
+		batch.Holdings = make(map[string]int)
+	}
+	return batch.Holdings
+}
+
+func (batch *ProductBatch) recalls() []string { return batch.RecallIds }
+func (batch *ProductBatch) recalled() bool    { return len(batch.RecallIds) > 0 }
+
+func (batch *ProductBatch) flagRecall(recallId string) {
+	batch.RecallIds = append(batch.RecallIds, recallId)
+}
+
+func (t *Controller) GetRawMaterialLotById(ctx *util.TxContext, id string) (RawMaterialLot, error) {
+	var asset RawMaterialLot
+	_, err := model.For(ctx).Get(id, &asset)
+	return asset, err
+}
+
+func (t *Controller) GetProductBatchById(ctx *util.TxContext, id string) (ProductBatch, error) {
+	var asset ProductBatch
+	_, err := model.For(ctx).Get(id, &asset)
+	return asset, err
+}
+
+// TraceForward follows a supplier's raw material lot to the product batches made from it,
+// their current holders and the customers they were sold to
+func (t *Controller) TraceForward(ctx *util.TxContext, lotId string) (TraceReport, error) {
+	var lot RawMaterialLot
+	if _, err := model.For(ctx).Get(lotId, &lot); err != nil {
+		return TraceReport{}, err
+	}
+	batchIds, err := model.For(ctx).GetIdsByCompositeKey(batchSourceIndex, []string{lotId}, 1)
+	if err != nil {
+		return TraceReport{}, err
+	}
+	batches, err := productBatches(ctx, batchIds)
+	if err != nil {
+		return TraceReport{}, err
+	}
+	return TraceReport{RawMaterialLots: []RawMaterialLot{lot}, ProductBatches: batches}, nil
+}
+
+// TraceBackward follows the products bought by a customer back to the supplier lots they were made from
+func (t *Controller) TraceBackward(ctx *util.TxContext, customerId string) (TraceReport, error) {
+	batchIds, err := model.For(ctx).GetIdsByCompositeKey(batchSaleIndex, []string{customerId}, 1)
+	if err != nil {
+		return TraceReport{}, err
+	}
+	batches, err := productBatches(ctx, batchIds)
+	if err != nil {
+		return TraceReport{}, err
+	}
+	report := TraceReport{RawMaterialLots: []RawMaterialLot{}, ProductBatches: batches}
+	seen := make(map[string]bool)
+	for _, batch := range batches {
+		for _, source := range batch.Sources {
+			if source.LotId == untrackedLot || seen[source.LotId] {
+				continue
+			}
+			seen[source.LotId] = true
+			var lot RawMaterialLot
+			if _, err := model.For(ctx).Get(source.LotId, &lot); err != nil {
+				return TraceReport{}, err
+			}
+			report.RawMaterialLots = append(report.RawMaterialLots, lot)
+		}
+	}
+	return report, nil
+}
+
+func productBatches(ctx *util.TxContext, ids []string) ([]ProductBatch, error) {
+	batches := []ProductBatch{}
+	for _, id := range ids {
+		var batch ProductBatch
+		if _, err := model.For(ctx).Get(id, &batch); err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+//-----------------------------------------------------------------------------
+//Recall
+//-----------------------------------------------------------------------------
+
+const (
+	RecallOpen   = "open"
+	RecallClosed = "closed"
+
+	recallIndex = "Recall~participant~id"
+)
+
+// raisedByCaller lets a recall be closed only by the organisation which raised it
+func raisedByCaller(ctx *util.TxContext, current interface{}, updated interface{}) error {
+	return authorizeRecallRaiser(ctx, updated.(*Recall).RaisedBy)
+}
+
+// authorizeRecallRaiser checks that the caller owns the raising manufacturer, or is a regulator when raisedBy is empty
+func authorizeRecallRaiser(ctx *util.TxContext, raisedBy string) error {
+	if raisedBy == "" {
+		regulator, err := model.For(ctx).IsRegulator()
+		if err != nil {
+			return fmt.Errorf("Access denied: %s", err.Error())
+		}
+		if !regulator {
+			return fmt.Errorf("Access denied: caller is not a member of a regulator MSP")
+		}
+		return nil
+	}
+	raiser, err := loadAsset(ctx, raisedBy)
+	if err != nil {
+		return err
+	}
+	if _, ok := raiser.(*Manufacturer); !ok {
+		return fmt.Errorf("Access denied: recalls can only be raised by a manufacturer or a regulator, %s is not a manufacturer", raisedBy)
+	}
+	return authorizeParty(ctx, raiser)
+}
+
+func (t *Controller) GetRecallById(ctx *util.TxContext, id string) (Recall, error) {
+	var asset Recall
+	_, err := model.For(ctx).Get(id, &asset)
+	return asset, err
+}
+
+// RaiseRecall flags supplier lots and product batches, and every batch made from those lots, so that they can no
+// longer be moved or sold. The distributors and retailers holding flagged stock must acknowledge the recall.
+// RaisedBy is the recalling manufacturer, or empty for a recall raised by a regulator.
+func (t *Controller) RaiseRecall(ctx *util.TxContext, asset Recall) (interface{}, error) {
+	if err := authorizeRecallRaiser(ctx, asset.RaisedBy); err != nil {
+		return nil, err
+	}
+	if len(asset.RawMaterialLots) == 0 && len(asset.ProductBatches) == 0 {
+		return nil, fmt.Errorf("Error in raising recall: no lots or batches given")
+	}
+
+	batch := newInventoryBatch(ctx)
+	flagged := make(map[string]bool)
+	flag := func(item string, id string) error {
+		if flagged[id] {
+			return nil
+		}
+		flagged[id] = true
+		lot, err := batch.lot(item, id)
+		if err != nil {
+			return err
+		}
+		lot.flagRecall(asset.RecallId)
+		batch.markLot(id)
+		return nil
+	}
+	affectedBatches := append([]string{}, asset.ProductBatches...)
+	for _, lotId := range asset.RawMaterialLots {
+		if err := flag(InventoryRawMaterial, lotId); err != nil {
+			return nil, err
+		}
+		batchIds, err := model.For(ctx).GetIdsByCompositeKey(batchSourceIndex, []string{lotId}, 1)
+		if err != nil {
+			return nil, err
+		}
+		affectedBatches = append(affectedBatches, batchIds...)
+	}
+	sort.Strings(affectedBatches)
+	asset.AffectedBatches = []string{}
+	holdings := make(map[string]int)
+	for _, batchId := range affectedBatches {
+		if flagged[batchId] {
+			continue
+		}
+		if err := flag(InventoryProducts, batchId); err != nil {
+			return nil, err
+		}
+		asset.AffectedBatches = append(asset.AffectedBatches, batchId)
+		for holder, quantity := range batch.lots[batchId].holdings() {
+			holdings[holder] += quantity
+		}
+	}
+
+	holders := make([]string, 0, len(holdings))
+	for holder := range holdings {
+		holders = append(holders, holder)
+	}
+	sort.Strings(holders)
+	asset.AffectedParties = []RecallParty{}
+	for _, holder := range holders {
+		participant, err := batch.participant(holder)
+		if err != nil {
+			return nil, err
+		}
+		switch participant.(type) {
+		case *Distributor, *Retailer:
+			asset.AffectedParties = append(asset.AffectedParties, RecallParty{ParticipantId: holder, Quantity: holdings[holder]})
+		}
+	}
+
+	if _, err := batch.commit(); err != nil {
+		return nil, err
+	}
+	asset.RaisedByMSP, _ = ctx.CreatorMSPID()
+	asset.Status = ""
+	if _, err := model.For(ctx).Save(&asset); err != nil {
+		return nil, err
+	}
+	for _, party := range asset.AffectedParties {
+		if err := model.For(ctx).PutIndexEntry(recallIndex, []string{party.ParticipantId, asset.RecallId}); err != nil {
+			return nil, err
+		}
+	}
+	if err := model.For(ctx).SetEvent("RecallRaised", asset); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// AcknowledgeRecall records that an affected distributor or retailer has taken note of a recall
+func (t *Controller) AcknowledgeRecall(ctx *util.TxContext, recallId string, participantId string) (interface{}, error) {
+	var recall Recall
+	if _, err := model.For(ctx).Get(recallId, &recall); err != nil {
+		return nil, err
+	}
+	for i := range recall.AffectedParties {
+		party := &recall.AffectedParties[i]
+		if party.ParticipantId != participantId {
+			continue
+		}
+		participant, err := loadAsset(ctx, participantId)
+		if err != nil {
+			return nil, err
+		}
+		if err := authorizeParty(ctx, participant); err != nil {
+			return nil, err
+		}
+		if party.Acknowledged {
+			return nil, fmt.Errorf("Error in acknowledging recall %s: %s has already acknowledged it", recallId, participantId)
+		}
+		party.Acknowledged = true
+		party.AcknowledgedBy, _ = ctx.CreatorMSPID()
+		party.AcknowledgedTxId = model.For(ctx).GetTransactionId()
+		return model.For(ctx).Update(&recall)
+	}
+	return nil, fmt.Errorf("Error in acknowledging recall %s: %s is not affected by it", recallId, participantId)
+}
+
+// CloseRecall closes a recall. The recalled stock stays blocked.
+func (t *Controller) CloseRecall(ctx *util.TxContext, recallId string) (interface{}, error) {
+	var recall Recall
+	if _, err := model.For(ctx).Get(recallId, &recall); err != nil {
+		return nil, err
+	}
+	recall.Status = RecallClosed
+	return model.For(ctx).Update(&recall)
+}
+
+// GetRecallsByParticipant returns the recalls affecting a distributor or retailer
+func (t *Controller) GetRecallsByParticipant(ctx *util.TxContext, participantId string) ([]Recall, error) {
+	ids, err := model.For(ctx).GetIdsByCompositeKey(recallIndex, []string{participantId}, 1)
+	if err != nil {
+		return nil, err
+	}
+	recalls := []Recall{}
+	for _, id := range ids {
+		var recall Recall
+		if _, err := model.For(ctx).Get(id, &recall); err != nil {
+			return nil, err
+		}
+		recalls = append(recalls, recall)
+	}
+	return recalls, nil
+}
+
+//-----------------------------------------------------------------------------
+//Invoice
+//-----------------------------------------------------------------------------
+
+const (
+	InvoiceIssued        = "issued"
+	InvoicePartiallyPaid = "partially-paid"
+	InvoicePaid          = "paid"
+	InvoiceCancelled     = "cancelled"
+
+	invoiceIndex       = "Invoice~party~id"
+	invoiceSourceIndex = "Invoice~movement~id"
+	paymentIndex       = "Payment~invoice~id"
+)
+
+// invoiceParty returns a guard letting only the given party of the invoice perform a transition
+func invoiceParty(party string) model.Guard {
+	return func(ctx *util.TxContext, current interface{}, updated interface{}) error {
+		invoice := updated.(*Invoice)
+		partyId := invoice.Buyer
+		if party == partySeller {
+			partyId = invoice.Seller
+		}
+		participant, err := loadAsset(ctx, partyId)
+		if err != nil {
+			return err
+		}
+		if err := authorizeParty(ctx, participant); err != nil {
+			return fmt.Errorf("only the %s may do this. %s", party, err.Error())
+		}
+		return nil
+	}
+}
+
+// settlementAccount returns the licence of the bank details or account a participant settles payments with
+func settlementAccount(participant interface{}) string {
+	switch p := participant.(type) {
+	case *Supplier:
+		return p.Account.License
+	case *Manufacturer:
+		return p.Bank_details.License
+	}
+	return ""
+}
+
+// CreateInvoice bills the goods of an inventory movement to their receiver: raw material supplied by a supplier to a
+// manufacturer, or products delivered to a retailer. Quantity and parties are taken from the movement, and amounts
+// is the unit price times the quantity. A movement can only be billed by one invoice which has not been cancelled.
+func (t *Controller) CreateInvoice(ctx *util.TxContext, asset Invoice) (interface{}, error) {
+	var movement InventoryMovement
+	if _, err := model.For(ctx).Get(asset.MovementId, &movement); err != nil {
+		return nil, err
+	}
+	if movement.From == "" || movement.To == "" {
+		return nil, fmt.Errorf("Error in saving invoice: movement %s is not a delivery between two participants", asset.MovementId)
+	}
+	seller, err := loadAsset(ctx, movement.From)
+	if err != nil {
+		return nil, err
+	}
+	buyer, err := loadAsset(ctx, movement.To)
+	if err != nil {
+		return nil, err
+	}
+	_, fromSupplier := seller.(*Supplier)
+	_, toManufacturer := buyer.(*Manufacturer)
+	_, toRetailer := buyer.(*Retailer)
+	if !(movement.Item == InventoryRawMaterial && fromSupplier && toManufacturer) && !(movement.Item == InventoryProducts && toRetailer) {
+		return nil, fmt.Errorf("Error in saving invoice: movement %s is neither raw material supplied to a manufacturer nor products delivered to a retailer", asset.MovementId)
+	}
+	if err := authorizeParty(ctx, seller); err != nil {
+		return nil, err
+	}
+	billed, err := model.For(ctx).GetIdsByCompositeKey(invoiceSourceIndex, []string{asset.MovementId}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(billed) > 0 {
+		return nil, fmt.Errorf("Error in saving invoice: movement %s is already billed by invoice %s", asset.MovementId, billed[0])
+	}
+
+	timestamp, err := transactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	asset.Seller = movement.From
+	asset.Buyer = movement.To
+	asset.Item = movement.Item
+	asset.Quantity = movement.Quantity
+	asset.Amount = asset.UnitPrice.MulInt(int64(movement.Quantity))
+	asset.Paid = decimal.New(0, asset.Amount.Scale())
+	asset.Status = ""
+	asset.IssuedTxId = model.For(ctx).GetTransactionId()
+	asset.IssuedAt = timestamp
+	if _, err := model.For(ctx).Save(&asset); err != nil {
+		return nil, err
+	}
+	for _, entry := range [][]string{
+		{invoiceIndex, asset.Seller, asset.InvoiceId},
+		{invoiceIndex, asset.Buyer, asset.InvoiceId},
+		{invoiceSourceIndex, asset.MovementId, asset.InvoiceId},
+	} {
+		if err := model.For(ctx).PutIndexEntry(entry[0], entry[1:]); err != nil {
+			return nil, err
+		}
+	}
+	return &asset, nil
+}
+
+func (t *Controller) GetInvoiceById(ctx *util.TxContext, id string) (Invoice, error) {
+	var asset Invoice
+	_, err := model.For(ctx).Get(id, &asset)
+	return asset, err
+}
+
+// CancelInvoice lets the seller withdraw an invoice on which nothing has been paid, so that the movement can be billed again
+func (t *Controller) CancelInvoice(ctx *util.TxContext, invoiceId string) (interface{}, error) {
+	var invoice Invoice
+	if _, err := model.For(ctx).Get(invoiceId, &invoice); err != nil {
+		return nil, err
+	}
+	invoice.Status = InvoiceCancelled
+	result, err := model.For(ctx).Update(&invoice)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.For(ctx).DelIndexEntry(invoiceSourceIndex, []string{invoice.MovementId, invoice.InvoiceId}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RecordPayment records a full or partial payment of an invoice by its buyer. Payments cannot exceed the amount outstanding.
+func (t *Controller) RecordPayment(ctx *util.TxContext, asset Payment) (interface{}, error) {
+	var invoice Invoice
+	if _, err := model.For(ctx).Get(asset.InvoiceId, &invoice); err != nil {
+		return nil, err
+	}
+	if invoice.Status == InvoiceCancelled || invoice.Status == InvoicePaid {
+		return nil, fmt.Errorf("Error in recording payment: invoice %s is %s", invoice.InvoiceId, invoice.Status)
+	}
+	seller, err := loadAsset(ctx, invoice.Seller)
+	if err != nil {
+		return nil, err
+	}
+	buyer, err := loadAsset(ctx, invoice.Buyer)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeParty(ctx, buyer); err != nil {
+		return nil, err
+	}
+	if asset.Amount.Sign() <= 0 {
+		return nil, fmt.Errorf("Error in recording payment: amount must be positive, given %s", asset.Amount.String())
+	}
+	outstanding := invoice.Amount.Sub(invoice.Paid)
+	if asset.Amount.Cmp(outstanding) > 0 {
+		return nil, fmt.Errorf("Error in recording payment: %s exceeds the %s outstanding on invoice %s", asset.Amount.String(), outstanding.String(), invoice.InvoiceId)
+	}
+
+	invoice.Paid = invoice.Paid.Add(asset.Amount)
+	invoice.Status = InvoicePartiallyPaid
+	if invoice.Paid.Equal(invoice.Amount) {
+		invoice.Status = InvoicePaid
+	}
+	if _, err := model.For(ctx).Update(&invoice); err != nil {
+		return nil, err
+	}
+	timestamp, err := transactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	asset.Payer = invoice.Buyer
+	asset.Payee = invoice.Seller
+	asset.PayerAccount = settlementAccount(buyer)
+	asset.PayeeAccount = settlementAccount(seller)
+	asset.TxId = model.For(ctx).GetTransactionId()
+	asset.Timestamp = timestamp
+	if _, err := model.For(ctx).Save(&asset); err != nil {
+		return nil, err
+	}
+	if err := model.For(ctx).PutIndexEntry(paymentIndex, []string{invoice.InvoiceId, asset.PaymentId}); err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// GetPaymentsByInvoice returns the payments made on an invoice, oldest first
+func (t *Controller) GetPaymentsByInvoice(ctx *util.TxContext, invoiceId string) ([]Payment, error) {
+	ids, err := model.For(ctx).GetIdsByCompositeKey(paymentIndex, []string{invoiceId}, 1)
+	if err != nil {
+		return nil, err
+	}
+	payments := []Payment{}
+	for _, id := range ids {
+		var payment Payment
+		if _, err := model.For(ctx).Get(id, &payment); err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	sort.SliceStable(payments, func(i, j int) bool {
+		return payments[i].Timestamp.Before(payments[j].Timestamp)
+	})
+	return payments, nil
+}
+
+// GetInvoicesByParticipant returns the invoices a participant has issued or received
+func (t *Controller) GetInvoicesByParticipant(ctx *util.TxContext, participantId string) ([]Invoice, error) {
+	ids, err := model.For(ctx).GetIdsByCompositeKey(invoiceIndex, []string{participantId}, 1)
+	if err != nil {
+		return nil, err
+	}
+	invoices := []Invoice{}
+	for _, id := range ids {
+		var invoice Invoice
+		if _, err := model.For(ctx).Get(id, &invoice); err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, invoice)
+	}
+	return invoices, nil
+}
+
+// GetPartyBalance reconciles the open invoices of a participant. Receivable is what counterparties still owe it,
+// payable what it still owes them, and net is receivable minus payable.
+func (t *Controller) GetPartyBalance(ctx *util.TxContext, participantId string) (PartyBalance, error) {
+	balance := PartyBalance{ParticipantId: participantId, Counterparties: []CounterpartyBalance{}}
+	invoices, err := t.GetInvoicesByParticipant(ctx, participantId)
+	if err != nil {
+		return balance, err
+	}
+	byCounterparty := make(map[string]*CounterpartyBalance)
+	var counterparties []string
+	for _, invoice := range invoices {
+		if invoice.Status == InvoiceCancelled {
+			continue
+		}
+		outstanding := invoice.Amount.Sub(invoice.Paid)
+		counterparty := invoice.Buyer
+		if invoice.Buyer == participantId {
+			counterparty = invoice.Seller
+		}
+		entry, ok := byCounterparty[counterparty]
+		if !ok {
+			entry = &CounterpartyBalance{Counterparty: counterparty}
+			byCounterparty[counterparty] = entry
+			counterparties = append(counterparties, counterparty)
+		}
+		if invoice.Seller == participantId {
+			entry.Receivable = entry.Receivable.Add(outstanding)
+			balance.Receivable = balance.Receivable.Add(outstanding)
+		} else {
+			entry.Payable = entry.Payable.Add(outstanding)
+			balance.Payable = balance.Payable.Add(outstanding)
+		}
+		entry.Net = entry.Receivable.Sub(entry.Payable)
+	}
+	sort.Strings(counterparties)
+	for _, counterparty := range counterparties {
+		balance.Counterparties = append(balance.Counterparties, *byCounterparty[counterparty])
+	}
+	balance.Net = balance.Receivable.Sub(balance.Payable)
+	return balance, nil
+}
+
+//-----------------------------------------------------------------------------
+//Offer
+//-----------------------------------------------------------------------------
+
+const (
+	OfferActive    = "active"
+	OfferWithdrawn = "withdrawn"
+
+	offerRetailerIndex = "OfferRedemption~retailer~offer~id"
+	offerCustomerIndex = "OfferRedemption~offer~customer~id"
+)
+
+// offerManager lets an offer be withdrawn by whoever may create it
+func offerManager(ctx *util.TxContext, current interface{}, updated interface{}) error {
+	return authorizeOfferManager(ctx, updated.(*Offer))
+}
+
+// authorizeOfferManager checks that the caller is an admin, or owns every retailer the offer is valid at
+func authorizeOfferManager(ctx *util.TxContext, offer *Offer) error {
+	if admin, err := model.For(ctx).IsAdmin(); err == nil && admin {
+		return nil
+	}
+	if len(offer.Retailers) == 0 {
+		return fmt.Errorf("Access denied: only an admin can manage an offer valid at every retailer")
+	}
+	for _, retailerId := range offer.Retailers {
+		retailer, err := loadAsset(ctx, retailerId)
+		if err != nil {
+			return err
+		}
+		if err := authorizeParty(ctx, retailer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateOffer creates an offer giving a percentage off sales of at least MinQuantity products, optionally capped
+// at MaxDiscount. An offer without Retailers is valid at every retailer, and a zero ValidFrom, ValidUntil, MaxUses
+// or MaxUsesPerCustomer leaves that limit open.
+func (t *Controller) CreateOffer(ctx *util.TxContext, asset Offer) (interface{}, error) {
+	for _, retailerId := range asset.Retailers {
+		retailer, err := loadAsset(ctx, retailerId)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := retailer.(*Retailer); !ok {
+			return nil, fmt.Errorf("Error in saving offer: %s is not a retailer", retailerId)
+		}
+	}
+	if !asset.ValidFrom.IsZero() && !asset.ValidUntil.IsZero() && !asset.ValidUntil.After(asset.ValidFrom) {
+		return nil, fmt.Errorf("Error in saving offer: ValidUntil must be later than ValidFrom")
+	}
+	if err := authorizeOfferManager(ctx, &asset); err != nil {
+		return nil, err
+	}
+	asset.Uses = 0
+	asset.Status = ""
+	return model.For(ctx).Save(&asset)
+}
+
+func (t *Controller) GetOfferById(ctx *util.TxContext, id string) (Offer, error) {
+	var asset Offer
+	_, err := model.For(ctx).Get(id, &asset)
+	return asset, err
+}
+
+func (t *Controller) WithdrawOffer(ctx *util.TxContext, offerId string) (interface{}, error) {
+	var offer Offer
+	if _, err := model.For(ctx).Get(offerId, &offer); err != nil {
+		return nil, err
+	}
+	offer.Status = OfferWithdrawn
+	return model.For(ctx).Update(&offer)
+}
+
+// checkOffer returns an error unless the offer can be applied to a sale of quantity products by the retailer to the customer
+func checkOffer(ctx *util.TxContext, offer *Offer, retailerId string, customerId string, quantity int) error {
+	if offer.Status != OfferActive {
+		return fmt.Errorf("Error in applying offer: offer %s is %s", offer.OfferId, offer.Status)
+	}
+	now, err := transactionTime(ctx)
+	if err != nil {
+		return err
+	}
+	if (!offer.ValidFrom.IsZero() && now.Before(offer.ValidFrom)) || (!offer.ValidUntil.IsZero() && !now.Before(offer.ValidUntil)) {
+		return fmt.Errorf("Error in applying offer: offer %s is not valid at %s", offer.OfferId, now.Format(date.CustomDateLayout))
+	}
+	if len(offer.Retailers) > 0 {
+		eligible := false
+		for _, id := range offer.Retailers {
+			eligible = eligible || id == retailerId
+		}
+		if !eligible {
+			return fmt.Errorf("Error in applying offer: offer %s is not valid at retailer %s", offer.OfferId, retailerId)
+		}
+	}
+	if quantity < offer.MinQuantity {
+		return fmt.Errorf("Error in applying offer: offer %s requires at least %d products, given %d", offer.OfferId, offer.MinQuantity, quantity)
+	}
+	if offer.MaxUses > 0 && offer.Uses >= offer.MaxUses {
+		return fmt.Errorf("Error in applying offer: offer %s has been used %d times, its limit", offer.OfferId, offer.Uses)
+	}
+	if offer.MaxUsesPerCustomer > 0 {
+		used, err := model.For(ctx).GetIdsByCompositeKey(offerCustomerIndex, []string{offer.OfferId, customerId}, 2)
+		if err != nil {
+			return err
+		}
+		if len(used) >= offer.MaxUsesPerCustomer {
+			return fmt.Errorf("Error in applying offer: customer %s has used offer %s %d times, its limit", customerId, offer.OfferId, len(used))
+		}
+	}
+	return nil
+}
+
+// SellWithOffer records a sale like RecordSale, at the given unit price less the offer's discount.
+// The offer's use is recorded on the customer, and counted against the offer's limits.
+func (t *Controller) SellWithOffer(ctx *util.TxContext, retailerId string, customerId string, quantity int, unitPrice decimal.Decimal, offerId string) (interface{}, error) {
+	var offer Offer
+	if _, err := model.For(ctx).Get(offerId, &offer); err != nil {
+		return nil, err
+	}
+	if err := checkOffer(ctx, &offer, retailerId, customerId, quantity); err != nil {
+		return nil, err
+	}
+	if unitPrice.Sign() < 0 {
+		return nil, fmt.Errorf("Error in applying offer: unit price must not be negative, given %s", unitPrice.String())
+	}
+
+	batch := newInventoryBatch(ctx)
+	customer, err := batch.modify(customerId)
+	if err != nil {
+		return nil, err
+	}
+	if customer, ok := customer.(*Customer); ok {
+		customer.OfferApplied++
+	}
+	receipt := &SaleReceipt{Retailer: retailerId, Customer: customerId, Quantity: quantity, OfferId: offerId}
+	if err := recordSale(ctx, batch, receipt, "sold with offer "+offerId); err != nil {
+		return nil, err
+	}
+
+	gross := unitPrice.MulInt(int64(quantity))
+	discount, err := gross.Mul(offer.DiscountPercent).Div(decimal.NewFromInt(100), gross.Scale())
+	if err != nil {
+		return nil, err
+	}
+	if offer.MaxDiscount.Sign() > 0 && discount.Cmp(offer.MaxDiscount) > 0 {
+		discount = offer.MaxDiscount
+	}
+	timestamp, err := transactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	redemption := OfferRedemption{
+		RedemptionId: model.For(ctx).NextTxScopedId("redemption"),
+		OfferId:      offerId,
+		ReceiptId:    receipt.ReceiptId,
+		Retailer:     retailerId,
+		Customer:     customerId,
+		Quantity:     quantity,
+		UnitPrice:    unitPrice,
+		GrossAmount:  gross,
+		Discount:     discount,
+		NetAmount:    gross.Sub(discount),
+		TxId:         model.For(ctx).GetTransactionId(),
+		Timestamp:    timestamp,
+	}
+	if _, err := model.For(ctx).Save(&redemption); err != nil {
+		return nil, err
+	}
+	offer.Uses++
+	if _, err := model.For(ctx).Update(&offer); err != nil {
+		return nil, err
+	}
+	for _, entry := range [][]string{
+		{offerRetailerIndex, retailerId, offerId, redemption.RedemptionId},
+		{offerCustomerIndex, offerId, customerId, redemption.RedemptionId},
+	} {
+		if err := model.For(ctx).PutIndexEntry(entry[0], entry[1:]); err != nil {
+			return nil, err
+		}
+	}
+	return &redemption, nil
+}
+
+// GetOfferUsageByRetailer reports, per offer, how often a retailer applied it and the discount given
+func (t *Controller) GetOfferUsageByRetailer(ctx *util.TxContext, retailerId string) ([]OfferUsage, error) {
+	ids, err := model.For(ctx).GetIdsByCompositeKey(offerRetailerIndex, []string{retailerId}, 2)
+	if err != nil {
+		return nil, err
+	}
+	usages := []OfferUsage{}
+	byOffer := make(map[string]int)
+	for _, id := range ids {
+		var redemption OfferRedemption
+		if _, err := model.For(ctx).Get(id, &redemption); err != nil {
+			return nil, err
+		}
+		i, ok := byOffer[redemption.OfferId]
+		if !ok {
+			i = len(usages)
+			byOffer[redemption.OfferId] = i
+			usages = append(usages, OfferUsage{OfferId: redemption.OfferId})
+		}
+		usages[i].Redemptions++
+		usages[i].Quantity += redemption.Quantity
+		usages[i].Discount = usages[i].Discount.Add(redemption.Discount)
+	}
+	sort.SliceStable(usages, func(i, j int) bool {
+		return usages[i].OfferId < usages[j].OfferId
+	})
+	return usages, nil
+}
+
+//-----------------------------------------------------------------------------
+//Sale
+//-----------------------------------------------------------------------------
+
+const saleReceiptIndex = "SaleReceipt~participant~id"
+
+// recordSale sells the receipt's quantity of products held by its retailer to its customer, on top of the changes
+// already made in the batch. The retailer's products available and sold and the customer's products bought are
+// updated, the batch is committed and the receipt completed, saved and announced with a SaleRecorded event.
+func recordSale(ctx *util.TxContext, batch *inventoryBatch, receipt *SaleReceipt, reason string) error {
+	ledger := model.For(ctx)
+	retailer, err := batch.participant(receipt.Retailer)
+	if err != nil {
+		return err
+	}
+	if _, ok := retailer.(*Retailer); !ok {
+		return fmt.Errorf("Error in recording sale: %s is not a retailer", receipt.Retailer)
+	}
+	if err := authorizeParty(ctx, retailer); err != nil {
+		return err
+	}
+	customer, err := batch.modify(receipt.Customer)
+	if err != nil {
+		return err
+	}
+	if _, ok := customer.(*Customer); !ok {
+		return fmt.Errorf("Error in recording sale: %s is not a customer", receipt.Customer)
+	}
+	if err := batch.sell(receipt.Retailer, receipt.Customer, receipt.Quantity, reason); err != nil {
+		return err
+	}
+	retailer, _ = batch.modify(receipt.Retailer)
+	retailer.(*Retailer).ProductsSold += receipt.Quantity
+	customer.(*Customer).ProductsBought += receipt.Quantity
+	customer.(*Customer).Received = true
+
+	movements, err := batch.commit()
+	if err != nil {
+		return err
+	}
+	sale := movements[len(movements)-1]
+	timestamp, err := transactionTime(ctx)
+	if err != nil {
+		return err
+	}
+	receipt.ReceiptId = ledger.NextTxScopedId("receipt")
+	receipt.MovementId = sale.MovementId
+	receipt.Lots = sale.Lots
+	receipt.TxId = ledger.GetTransactionId()
+	receipt.Timestamp = timestamp
+	if _, err := ledger.Save(receipt); err != nil {
+		return err
+	}
+	for _, participantId := range []string{receipt.Retailer, receipt.Customer} {
+		if err := ledger.PutIndexEntry(saleReceiptIndex, []string{participantId, receipt.ReceiptId}); err != nil {
+			return err
+		}
+	}
+	return ledger.SetEvent("SaleRecorded", receipt)
+}
+
+// RecordSale sells products held by a retailer to a customer in one transaction, updating both and issuing a receipt
+func (t *Controller) RecordSale(ctx *util.TxContext, retailerId string, customerId string, quantity int) (interface{}, error) {
+	receipt := &SaleReceipt{Retailer: retailerId, Customer: customerId, Quantity: quantity}
+	if err := recordSale(ctx, newInventoryBatch(ctx), receipt, "sold"); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+func (t *Controller) GetSaleReceiptById(ctx *util.TxContext, id string) (SaleReceipt, error) {
+	var asset SaleReceipt
+	_, err := model.For(ctx).Get(id, &asset)
+	return asset, err
+}
+
+// GetSaleReceiptsByParticipant returns the receipts of the sales made by a retailer or to a customer, oldest first
+func (t *Controller) GetSaleReceiptsByParticipant(ctx *util.TxContext, participantId string) ([]SaleReceipt, error) {
+	ids, err := model.For(ctx).GetIdsByCompositeKey(saleReceiptIndex, []string{participantId}, 1)
+	if err != nil {
+		return nil, err
+	}
+	receipts := []SaleReceipt{}
+	for _, id := range ids {
+		var receipt SaleReceipt
+		if _, err := model.For(ctx).Get(id, &receipt); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	sort.SliceStable(receipts, func(i, j int) bool {
+		return receipts[i].Timestamp.Before(receipts[j].Timestamp)
+	})
+	return receipts, nil
+}
+
+//-----------------------------------------------------------------------------
+//Report
+//-----------------------------------------------------------------------------
+
+// GetReport computes counts and totals over the assets of one type, grouped and filtered as described by spec
+func (t *Controller) GetReport(ctx *util.TxContext, spec model.ReportSpec) ([]model.ReportRow, error) {
+	return model.For(ctx).Report(spec)
+}
+
+// presetReport runs a report with the caller's grouping, or defaultGroupBy when none is given, and the
+// caller's filter on top of the fixed one
+func presetReport(ctx *util.TxContext, assetType string, sum []string, fixed map[string]interface{}, defaultGroupBy []string, groupBy []string, filter map[string]interface{}) ([]model.ReportRow, error) {
+	if len(groupBy) == 0 {
+		groupBy = defaultGroupBy
+	}
+	combined := make(map[string]interface{})
+	for field, value := range filter {
+		combined[field] = value
+	}
+	for field, value := range fixed {
+		combined[field] = value
+	}
+	return model.For(ctx).Report(model.ReportSpec{AssetType: assetType, GroupBy: groupBy, Sum: sum, Filter: combined})
+}
+
+// GetRawMaterialReport totals the raw material held by active suppliers, in one row unless grouped
+func (t *Controller) GetRawMaterialReport(ctx *util.TxContext, groupBy []string, filter map[string]interface{}) ([]model.ReportRow, error) {
+	active := map[string]interface{}{"Active": true, "Status": SupplierActive}
+	return presetReport(ctx, "Supplier", []string{"RawMaterialAvailable"}, active, nil, groupBy, filter)
+}
+
+// GetManufacturerProductsReport totals products and raw material held, per manufacturer unless grouped otherwise
+func (t *Controller) GetManufacturerProductsReport(ctx *util.TxContext, groupBy []string, filter map[string]interface{}) ([]model.ReportRow, error) {
+	return presetReport(ctx, "Manufacturer", []string{"ProductsAvailable", "RawMaterialAvailable"}, nil, []string{"ManufacturerId"}, groupBy, filter)
+}
+
+// GetDistributionReport compares products shipped and received, per distributor unless grouped otherwise
+func (t *Controller) GetDistributionReport(ctx *util.TxContext, groupBy []string, filter map[string]interface{}) ([]model.ReportRow, error) {
+	return presetReport(ctx, "Distributor", []string{"ProductsShipped", "ProductsReceived", "ProductsToBeShipped"}, nil, []string{"DistributorId"}, groupBy, filter)
+}
+
+// GetRetailSalesReport totals products sold and in stock, per retailer unless grouped otherwise
+func (t *Controller) GetRetailSalesReport(ctx *util.TxContext, groupBy []string, filter map[string]interface{}) ([]model.ReportRow, error) {
+	return presetReport(ctx, "Retailer", []string{"ProductsSold", "ProductsAvailable"}, nil, []string{"RetailerId"}, groupBy, filter)
+}
+
+// The Get<Asset>Schema methods return the JSON Schema (draft 2020-12) of an asset, translated from its
+// validate, mandatory, default and id tags, for clients to check documents before submitting them
+func (t *Controller) GetBank_detailsSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Bank_details{}), nil
+}
+
+func (t *Controller) GetCustomerSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Customer{}), nil
+}
+
+func (t *Controller) GetRetailerSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Retailer{}), nil
+}
+
+func (t *Controller) GetAccountSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Account{}), nil
+}
+
+func (t *Controller) GetSupplierSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Supplier{}), nil
+}
+
+func (t *Controller) GetLicenseRenewalSchema() (validators.Schema, error) {
+	return validators.JSONSchema(LicenseRenewal{}), nil
+}
+
+func (t *Controller) GetManufacturerSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Manufacturer{}), nil
+}
+
+func (t *Controller) GetDistributorSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Distributor{}), nil
+}
+
+func (t *Controller) GetInventoryMovementSchema() (validators.Schema, error) {
+	return validators.JSONSchema(InventoryMovement{}), nil
+}
+
+func (t *Controller) GetPurchaseOrderSchema() (validators.Schema, error) {
+	return validators.JSONSchema(PurchaseOrder{}), nil
+}
+
+func (t *Controller) GetShipmentSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Shipment{}), nil
+}
+
+func (t *Controller) GetRawMaterialLotSchema() (validators.Schema, error) {
+	return validators.JSONSchema(RawMaterialLot{}), nil
+}
+
+func (t *Controller) GetProductBatchSchema() (validators.Schema, error) {
+	return validators.JSONSchema(ProductBatch{}), nil
+}
+
+func (t *Controller) GetRecallSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Recall{}), nil
+}
+
+func (t *Controller) GetInvoiceSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Invoice{}), nil
+}
+
+func (t *Controller) GetPaymentSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Payment{}), nil
+}
+
+func (t *Controller) GetOfferSchema() (validators.Schema, error) {
+	return validators.JSONSchema(Offer{}), nil
+}
+
+func (t *Controller) GetOfferRedemptionSchema() (validators.Schema, error) {
+	return validators.JSONSchema(OfferRedemption{}), nil
+}
+
+func (t *Controller) GetSaleReceiptSchema() (validators.Schema, error) {
+	return validators.JSONSchema(SaleReceipt{}), nil
+}
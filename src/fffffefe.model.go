@@ -6,7 +6,9 @@
 package src
 
 import (
+	"example.com/fffffefe/lib/model"
 	"example.com/fffffefe/lib/util/date"
+	"example.com/fffffefe/lib/util/decimal"
 )
 
 type Bank_details struct {
@@ -25,7 +27,7 @@ type Customer struct {
 	CustomerId     string       `json:"CustomerId" validate:"string" id:"true" mandatory:"true"`
 	Name           string       `json:"Name" validate:"string" mandatory:"true"`
 	ProductsBought int          `json:"ProductsBought" validate:"int"`
-	OfferApplied   int          `json:"OfferApplied" validate:"int,max=0"`
+	OfferApplied   int          `json:"OfferApplied" validate:"int,min=0"`
 	PhoneNumber    string       `json:"PhoneNumber" validate:"string,regexp=^\\(?([0-9]{3})\\)?[-. ]?([0-9]{3})[-. ]?([0-9]{4})$"`
 	Received       bool         `json:"Received" validate:"bool"`
 	Bank_details   Bank_details `json:"Bank_details" validate:""`
@@ -39,10 +41,11 @@ type Retailer struct {
 	Customer          Customer    `json:"Customer" validate:""`
 	ProductsOrdered   int         `json:"ProductsOrdered" validate:"int" mandatory:"true"`
 	ProductsAvailable int         `json:"ProductsAvailable" validate:"int" default:"1"`
-	ProductsSold      int         `json:"ProductsSold" validate:"int"`
+	ProductsSold      int         `json:"ProductsSold" validate:"int" couchIndex:"byProductsSold"`
 	Remarks           string      `json:"Remarks" validate:"string" default:"open for business"`
 	Items             []int       `json:"Items" validate:"array=int,range=1-5"`
 	Domain            string      `json:"Domain" validate:"string,url,min=30,max=50"`
+	OwnerMSP          string      `json:"OwnerMSP" validate:"string"`
 	Metadata          interface{} `json:"Metadata,omitempty"`
 }
 
@@ -51,7 +54,7 @@ type Account struct {
 
 	RawMaterialAvailable int         `json:"RawMaterialAvailable" validate:"int,min=0"`
 	License              string      `json:"License" validate:"string,min=2,max=4"`
-	ExpiryDate           date.Date   `json:"ExpiryDate" validate:"date,before=2020-06-26"`
+	ExpiryDate           date.Date   `json:"ExpiryDate" validate:"date"`
 	Active               bool        `json:"Active" validate:"bool" default:"true"`
 	Metadata             interface{} `json:"Metadata,omitempty"`
 }
@@ -61,23 +64,61 @@ type Supplier struct {
 
 	SupplierId           string      `json:"SupplierId" validate:"string,regexp=^[a-zA-Z]$" id:"true" mandatory:"true"`
 	Retailer             Retailer    `json:"Retailer" validate:""`
-	RawMaterialAvailable int         `json:"RawMaterialAvailable" validate:"int,min=0"`
-	License              string      `json:"License" validate:"string,min=2,max=4"`
-	ExpiryDate           date.Date   `json:"ExpiryDate" validate:"date,before=2020-06-26"`
+	RawMaterialAvailable int         `json:"RawMaterialAvailable" validate:"int,min=0" couchIndex:"byRawMaterial"`
+	License              string      `json:"License" validate:"string,min=2,max=4" couchIndex:"byLicense"`
+	ExpiryDate           date.Date   `json:"ExpiryDate" validate:"date" couchIndex:"byExpiry"`
 	Active               bool        `json:"Active" validate:"bool" default:"true"`
 	Account              Account     `json:"Account" validate:""`
+	OwnerMSP             string      `json:"OwnerMSP" validate:"string"`
+	Status               string      `json:"Status" validate:"string" default:"active" couchIndex:"byStatus"`
 	Metadata             interface{} `json:"Metadata,omitempty"`
 }
 
+func (asset *Supplier) StateMachine() *model.StateMachine {
+	return &model.StateMachine{
+		Field:   "Status",
+		Initial: []string{SupplierActive},
+		Transitions: []model.Transition{
+			{From: SupplierActive, To: SupplierSuspended, Guard: ownerOrAdmin},
+			{From: SupplierSuspended, To: SupplierActive, Guard: adminOnly},
+			{From: SupplierActive, To: SupplierRevoked, Guard: adminOnly},
+			{From: SupplierSuspended, To: SupplierRevoked, Guard: adminOnly},
+		},
+	}
+}
+
+type LicenseRenewal struct {
+	AssetType string `json:"AssetType" final:"fffffefe.LicenseRenewal"`
+
+	RenewalId          string      `json:"RenewalId" validate:"string" id:"true" mandatory:"true"`
+	SupplierId         string      `json:"SupplierId" validate:"string" mandatory:"true"`
+	PreviousLicense    string      `json:"PreviousLicense" validate:"string"`
+	PreviousExpiryDate date.Date   `json:"PreviousExpiryDate" validate:"date"`
+	License            string      `json:"License" validate:"string,min=2,max=4"`
+	ExpiryDate         date.Date   `json:"ExpiryDate" validate:"date"`
+	RenewedBy          string      `json:"RenewedBy" validate:"string"`
+	TxId               string      `json:"TxId" validate:"string"`
+	Timestamp          date.Date   `json:"Timestamp" validate:"date"`
+	Metadata           interface{} `json:"Metadata,omitempty"`
+}
+
+type LicenseExpiry struct {
+	SupplierId string    `json:"SupplierId"`
+	License    string    `json:"License"`
+	ExpiryDate date.Date `json:"ExpiryDate"`
+	DaysLeft   int       `json:"DaysLeft"`
+}
+
 type Manufacturer struct {
 	AssetType string `json:"AssetType" final:"fffffefe.Manufacturer"`
 
 	ManufacturerId       string       `json:"ManufacturerId" validate:"string" id:"true" mandatory:"true"`
 	Bank_details         Bank_details `json:"Bank_details" validate:""`
 	RawMaterialAvailable int          `json:"RawMaterialAvailable" validate:"int,max=8"`
-	ProductsAvailable    int          `json:"ProductsAvailable" validate:"int"`
+	ProductsAvailable    int          `json:"ProductsAvailable" validate:"int" couchIndex:"byProducts"`
 	CompletionDate       date.Date    `json:"CompletionDate" validate:"date,after=2020-06-26T02:30:55Z,before=2020-06-28T02:30:55Z"`
 	Account              Account      `json:"Account" validate:""`
+	OwnerMSP             string       `json:"OwnerMSP" validate:"string"`
 	Metadata             interface{}  `json:"Metadata,omitempty"`
 }
 
@@ -89,6 +130,328 @@ type Distributor struct {
 	ProductsShipped     int         `json:"ProductsShipped" validate:"int,min=3"`
 	ProductsReceived    int         `json:"ProductsReceived" validate:"int"`
 	MailId              string      `json:"MailId" validate:"string,email"`
-	DistributionDate    date.Date   `json:"DistributionDate" validate:"date"`
+	DistributionDate    date.Date   `json:"DistributionDate" validate:"date" couchIndex:"byDistributionDate"`
+	OwnerMSP            string      `json:"OwnerMSP" validate:"string"`
+	ShipmentStatus      string      `json:"ShipmentStatus" validate:"string" default:"pending"`
 	Metadata            interface{} `json:"Metadata,omitempty"`
 }
+
+func (asset *Distributor) StateMachine() *model.StateMachine {
+	return &model.StateMachine{
+		Field:   "ShipmentStatus",
+		Initial: []string{ShipmentPending},
+		Transitions: []model.Transition{
+			{From: ShipmentPending, To: ShipmentInTransit, Guard: hasProductsToShip},
+			{From: ShipmentInTransit, To: ShipmentDelivered},
+			{From: ShipmentDelivered, To: ShipmentPending},
+		},
+	}
+}
+
+type InventoryMovement struct {
+	AssetType string `json:"AssetType" final:"fffffefe.InventoryMovement"`
+
+	MovementId string        `json:"MovementId" validate:"string" id:"true" mandatory:"true"`
+	Item       string        `json:"Item" validate:"string" mandatory:"true"`
+	From       string        `json:"From" validate:"string"`
+	To         string        `json:"To" validate:"string"`
+	Quantity   int           `json:"Quantity" validate:"int,min=1" mandatory:"true"`
+	Reason     string        `json:"Reason" validate:"string"`
+	TxId       string        `json:"TxId" validate:"string"`
+	Timestamp  date.Date     `json:"Timestamp" validate:"date" couchIndex:"byTimestamp"`
+	Lots       []LotQuantity `json:"Lots" validate:"array"`
+	Metadata   interface{}   `json:"Metadata,omitempty"`
+}
+
+type InventoryBalance struct {
+	ParticipantId string `json:"ParticipantId"`
+	Item          string `json:"Item"`
+	Recorded      int    `json:"Recorded"`
+	Derived       int    `json:"Derived"`
+	Consistent    bool   `json:"Consistent"`
+}
+
+type PurchaseOrderLine struct {
+	Product  string `json:"Product" validate:"string"`
+	Quantity int    `json:"Quantity" validate:"int,min=1"`
+}
+
+type PurchaseOrderTransition struct {
+	From      string    `json:"From" validate:"string"`
+	To        string    `json:"To" validate:"string"`
+	By        string    `json:"By" validate:"string"`
+	TxId      string    `json:"TxId" validate:"string"`
+	Timestamp date.Date `json:"Timestamp" validate:"date"`
+}
+
+type PurchaseOrder struct {
+	AssetType string `json:"AssetType" final:"fffffefe.PurchaseOrder"`
+
+	PurchaseOrderId string                    `json:"PurchaseOrderId" validate:"string" id:"true" mandatory:"true"`
+	Buyer           string                    `json:"Buyer" validate:"string" mandatory:"true"`
+	Seller          string                    `json:"Seller" validate:"string" mandatory:"true"`
+	Lines           []PurchaseOrderLine       `json:"Lines" validate:"array,range=1-" mandatory:"true"`
+	Status          string                    `json:"Status" validate:"string" couchIndex:"byStatus"`
+	CancelReason    string                    `json:"CancelReason" validate:"string"`
+	History         []PurchaseOrderTransition `json:"History" validate:"array"`
+	Metadata        interface{}               `json:"Metadata,omitempty"`
+}
+
+func (asset *PurchaseOrder) StateMachine() *model.StateMachine {
+	return &model.StateMachine{
+		Field:   "Status",
+		Initial: []string{PurchaseOrderDraft},
+		Transitions: []model.Transition{
+			{From: PurchaseOrderDraft, To: PurchaseOrderSubmitted, Guard: purchaseOrderParty(partyBuyer)},
+			{From: PurchaseOrderSubmitted, To: PurchaseOrderAccepted, Guard: purchaseOrderParty(partySeller)},
+			{From: PurchaseOrderAccepted, To: PurchaseOrderShipped, Guard: purchaseOrderParty(partySeller)},
+			{From: PurchaseOrderShipped, To: PurchaseOrderReceived, Guard: purchaseOrderParty(partyBuyer)},
+			{From: PurchaseOrderReceived, To: PurchaseOrderClosed, Guard: purchaseOrderParty(partyBuyer, partySeller)},
+			{From: PurchaseOrderDraft, To: PurchaseOrderCancelled, Guard: purchaseOrderParty(partyBuyer)},
+			{From: PurchaseOrderSubmitted, To: PurchaseOrderCancelled, Guard: purchaseOrderParty(partyBuyer, partySeller)},
+			{From: PurchaseOrderAccepted, To: PurchaseOrderCancelled, Guard: purchaseOrderParty(partySeller)},
+		},
+	}
+}
+
+type CustodyTransfer struct {
+	From      string    `json:"From" validate:"string"`
+	To        string    `json:"To" validate:"string"`
+	Quantity  int       `json:"Quantity" validate:"int"`
+	SignedBy  string    `json:"SignedBy" validate:"string"`
+	TxId      string    `json:"TxId" validate:"string"`
+	Timestamp date.Date `json:"Timestamp" validate:"date"`
+}
+
+type Shipment struct {
+	AssetType string `json:"AssetType" final:"fffffefe.Shipment"`
+
+	ShipmentId      string            `json:"ShipmentId" validate:"string" id:"true" mandatory:"true"`
+	PurchaseOrderId string            `json:"PurchaseOrderId" validate:"string"`
+	Manufacturer    string            `json:"Manufacturer" validate:"string" mandatory:"true"`
+	Distributor     string            `json:"Distributor" validate:"string" mandatory:"true"`
+	Retailer        string            `json:"Retailer" validate:"string" mandatory:"true"`
+	Quantity        int               `json:"Quantity" validate:"int,min=1" mandatory:"true"`
+	Status          string            `json:"Status" validate:"string" couchIndex:"byStatus"`
+	Custodian       string            `json:"Custodian" validate:"string"`
+	CustodyChain    []CustodyTransfer `json:"CustodyChain" validate:"array"`
+	Lots            []LotQuantity     `json:"Lots" validate:"array"`
+	Metadata        interface{}       `json:"Metadata,omitempty"`
+}
+
+func (asset *Shipment) StateMachine() *model.StateMachine {
+	return &model.StateMachine{
+		Field:   "Status",
+		Initial: []string{ShipmentAtManufacturer},
+		Transitions: []model.Transition{
+			{From: ShipmentAtManufacturer, To: ShipmentAtDistributor, Guard: signedByCustodian},
+			{From: ShipmentAtDistributor, To: ShipmentAtRetailer, Guard: signedByCustodian},
+		},
+	}
+}
+
+type LotQuantity struct {
+	LotId    string `json:"LotId" validate:"string"`
+	Origin   string `json:"Origin" validate:"string"`
+	Quantity int    `json:"Quantity" validate:"int"`
+}
+
+type RawMaterialLot struct {
+	AssetType string `json:"AssetType" final:"fffffefe.RawMaterialLot"`
+
+	LotId     string         `json:"LotId" validate:"string" id:"true" mandatory:"true"`
+	Supplier  string         `json:"Supplier" validate:"string" mandatory:"true"`
+	Quantity  int            `json:"Quantity" validate:"int,min=1"`
+	Holdings  map[string]int `json:"Holdings"`
+	Created   date.Date      `json:"Created" validate:"date"`
+	TxId      string         `json:"TxId" validate:"string"`
+	RecallIds []string       `json:"RecallIds" validate:"array"`
+	Metadata  interface{}    `json:"Metadata,omitempty"`
+}
+
+type BatchSale struct {
+	Retailer  string    `json:"Retailer" validate:"string"`
+	Customer  string    `json:"Customer" validate:"string"`
+	Quantity  int       `json:"Quantity" validate:"int"`
+	TxId      string    `json:"TxId" validate:"string"`
+	Timestamp date.Date `json:"Timestamp" validate:"date"`
+}
+
+type ProductBatch struct {
+	AssetType string `json:"AssetType" final:"fffffefe.ProductBatch"`
+
+	BatchId      string         `json:"BatchId" validate:"string" id:"true" mandatory:"true"`
+	Manufacturer string         `json:"Manufacturer" validate:"string" mandatory:"true"`
+	Quantity     int            `json:"Quantity" validate:"int,min=1"`
+	Sources      []LotQuantity  `json:"Sources" validate:"array"`
+	Holdings     map[string]int `json:"Holdings"`
+	Sales        []BatchSale    `json:"Sales" validate:"array"`
+	Created      date.Date      `json:"Created" validate:"date"`
+	TxId         string         `json:"TxId" validate:"string"`
+	RecallIds    []string       `json:"RecallIds" validate:"array"`
+	Metadata     interface{}    `json:"Metadata,omitempty"`
+}
+
+type TraceReport struct {
+	RawMaterialLots []RawMaterialLot `json:"RawMaterialLots"`
+	ProductBatches  []ProductBatch   `json:"ProductBatches"`
+}
+
+type RecallParty struct {
+	ParticipantId    string `json:"ParticipantId" validate:"string"`
+	Quantity         int    `json:"Quantity" validate:"int"`
+	Acknowledged     bool   `json:"Acknowledged" validate:"bool"`
+	AcknowledgedBy   string `json:"AcknowledgedBy" validate:"string"`
+	AcknowledgedTxId string `json:"AcknowledgedTxId" validate:"string"`
+}
+
+type Recall struct {
+	AssetType string `json:"AssetType" final:"fffffefe.Recall"`
+
+	RecallId        string        `json:"RecallId" validate:"string" id:"true" mandatory:"true"`
+	RaisedBy        string        `json:"RaisedBy" validate:"string"`
+	RaisedByMSP     string        `json:"RaisedByMSP" validate:"string"`
+	Reason          string        `json:"Reason" validate:"string" mandatory:"true"`
+	RawMaterialLots []string      `json:"RawMaterialLots" validate:"array"`
+	ProductBatches  []string      `json:"ProductBatches" validate:"array"`
+	AffectedBatches []string      `json:"AffectedBatches" validate:"array"`
+	AffectedParties []RecallParty `json:"AffectedParties" validate:"array"`
+	Status          string        `json:"Status" validate:"string"`
+	Metadata        interface{}   `json:"Metadata,omitempty"`
+}
+
+func (asset *Recall) StateMachine() *model.StateMachine {
+	return &model.StateMachine{
+		Field:       "Status",
+		Initial:     []string{RecallOpen},
+		Transitions: []model.Transition{{From: RecallOpen, To: RecallClosed, Guard: raisedByCaller}},
+	}
+}
+
+type Invoice struct {
+	AssetType string `json:"AssetType" final:"fffffefe.Invoice"`
+
+	InvoiceId  string          `json:"InvoiceId" validate:"string" id:"true" mandatory:"true"`
+	Seller     string          `json:"Seller" validate:"string"`
+	Buyer      string          `json:"Buyer" validate:"string"`
+	MovementId string          `json:"MovementId" validate:"string" mandatory:"true"`
+	Item       string          `json:"Item" validate:"string"`
+	Quantity   int             `json:"Quantity" validate:"int"`
+	UnitPrice  decimal.Decimal `json:"UnitPrice" validate:"decimal,positive,scale=2" mandatory:"true"`
+	Amount     decimal.Decimal `json:"Amount" validate:"decimal,scale=2"`
+	Paid       decimal.Decimal `json:"Paid" validate:"decimal,scale=2"`
+	Status     string          `json:"Status" validate:"string" couchIndex:"byStatus"`
+	IssuedTxId string          `json:"IssuedTxId" validate:"string"`
+	IssuedAt   date.Date       `json:"IssuedAt" validate:"date"`
+	Metadata   interface{}     `json:"Metadata,omitempty"`
+}
+
+func (asset *Invoice) StateMachine() *model.StateMachine {
+	return &model.StateMachine{
+		Field:   "Status",
+		Initial: []string{InvoiceIssued},
+		Transitions: []model.Transition{
+			{From: InvoiceIssued, To: InvoicePartiallyPaid, Guard: invoiceParty(partyBuyer)},
+			{From: InvoiceIssued, To: InvoicePaid, Guard: invoiceParty(partyBuyer)},
+			{From: InvoicePartiallyPaid, To: InvoicePaid, Guard: invoiceParty(partyBuyer)},
+			{From: InvoiceIssued, To: InvoiceCancelled, Guard: invoiceParty(partySeller)},
+		},
+	}
+}
+
+type Payment struct {
+	AssetType string `json:"AssetType" final:"fffffefe.Payment"`
+
+	PaymentId    string          `json:"PaymentId" validate:"string" id:"true" mandatory:"true"`
+	InvoiceId    string          `json:"InvoiceId" validate:"string" mandatory:"true"`
+	Amount       decimal.Decimal `json:"Amount" validate:"decimal,positive,scale=2" mandatory:"true"`
+	Reference    string          `json:"Reference" validate:"string"`
+	Payer        string          `json:"Payer" validate:"string"`
+	Payee        string          `json:"Payee" validate:"string"`
+	PayerAccount string          `json:"PayerAccount" validate:"string"`
+	PayeeAccount string          `json:"PayeeAccount" validate:"string"`
+	TxId         string          `json:"TxId" validate:"string"`
+	Timestamp    date.Date       `json:"Timestamp" validate:"date"`
+	Metadata     interface{}     `json:"Metadata,omitempty"`
+}
+
+type CounterpartyBalance struct {
+	Counterparty string          `json:"Counterparty"`
+	Receivable   decimal.Decimal `json:"Receivable"`
+	Payable      decimal.Decimal `json:"Payable"`
+	Net          decimal.Decimal `json:"Net"`
+}
+
+type PartyBalance struct {
+	ParticipantId  string                `json:"ParticipantId"`
+	Receivable     decimal.Decimal       `json:"Receivable"`
+	Payable        decimal.Decimal       `json:"Payable"`
+	Net            decimal.Decimal       `json:"Net"`
+	Counterparties []CounterpartyBalance `json:"Counterparties"`
+}
+
+type Offer struct {
+	AssetType string `json:"AssetType" final:"fffffefe.Offer"`
+
+	OfferId            string          `json:"OfferId" validate:"string" id:"true" mandatory:"true"`
+	Description        string          `json:"Description" validate:"string"`
+	DiscountPercent    decimal.Decimal `json:"DiscountPercent" validate:"decimal,decimalMin=0,decimalMax=100" mandatory:"true"`
+	MaxDiscount        decimal.Decimal `json:"MaxDiscount" validate:"decimal,positive"`
+	MinQuantity        int             `json:"MinQuantity" validate:"int,min=0"`
+	ValidFrom          date.Date       `json:"ValidFrom" validate:"date"`
+	ValidUntil         date.Date       `json:"ValidUntil" validate:"date"`
+	Retailers          []string        `json:"Retailers" validate:"array"`
+	MaxUses            int             `json:"MaxUses" validate:"int,min=0"`
+	MaxUsesPerCustomer int             `json:"MaxUsesPerCustomer" validate:"int,min=0"`
+	Uses               int             `json:"Uses" validate:"int"`
+	Status             string          `json:"Status" validate:"string"`
+	Metadata           interface{}     `json:"Metadata,omitempty"`
+}
+
+func (asset *Offer) StateMachine() *model.StateMachine {
+	return &model.StateMachine{
+		Field:       "Status",
+		Initial:     []string{OfferActive},
+		Transitions: []model.Transition{{From: OfferActive, To: OfferWithdrawn, Guard: offerManager}},
+	}
+}
+
+type OfferRedemption struct {
+	AssetType string `json:"AssetType" final:"fffffefe.OfferRedemption"`
+
+	RedemptionId string          `json:"RedemptionId" validate:"string" id:"true" mandatory:"true"`
+	OfferId      string          `json:"OfferId" validate:"string"`
+	ReceiptId    string          `json:"ReceiptId" validate:"string"`
+	Retailer     string          `json:"Retailer" validate:"string"`
+	Customer     string          `json:"Customer" validate:"string"`
+	Quantity     int             `json:"Quantity" validate:"int"`
+	UnitPrice    decimal.Decimal `json:"UnitPrice" validate:"decimal"`
+	GrossAmount  decimal.Decimal `json:"GrossAmount" validate:"decimal"`
+	Discount     decimal.Decimal `json:"Discount" validate:"decimal"`
+	NetAmount    decimal.Decimal `json:"NetAmount" validate:"decimal"`
+	TxId         string          `json:"TxId" validate:"string"`
+	Timestamp    date.Date       `json:"Timestamp" validate:"date"`
+	Metadata     interface{}     `json:"Metadata,omitempty"`
+}
+
+type OfferUsage struct {
+	OfferId     string          `json:"OfferId"`
+	Redemptions int             `json:"Redemptions"`
+	Quantity    int             `json:"Quantity"`
+	Discount    decimal.Decimal `json:"Discount"`
+}
+
+type SaleReceipt struct {
+	AssetType string `json:"AssetType" final:"fffffefe.SaleReceipt"`
+
+	ReceiptId  string        `json:"ReceiptId" validate:"string" id:"true" mandatory:"true"`
+	Retailer   string        `json:"Retailer" validate:"string"`
+	Customer   string        `json:"Customer" validate:"string"`
+	Quantity   int           `json:"Quantity" validate:"int,min=1"`
+	OfferId    string        `json:"OfferId" validate:"string"`
+	MovementId string        `json:"MovementId" validate:"string"`
+	Lots       []LotQuantity `json:"Lots" validate:"array"`
+	TxId       string        `json:"TxId" validate:"string"`
+	Timestamp  date.Date     `json:"Timestamp" validate:"date"`
+	Metadata   interface{}   `json:"Metadata,omitempty"`
+}
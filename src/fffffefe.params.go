@@ -0,0 +1,113 @@
+// Code generated by go generate; DO NOT EDIT.
+
+package src
+
+// methodParams lists the parameter names of the Controller methods, which reflection does not give
+var methodParams = map[string][]string{
+	"AcceptPurchaseOrder":                {"ctx", "id"},
+	"AcknowledgeRecall":                  {"ctx", "recallId", "participantId"},
+	"CancelInvoice":                      {"ctx", "invoiceId"},
+	"CancelPurchaseOrder":                {"ctx", "id", "reason"},
+	"ClosePurchaseOrder":                 {"ctx", "id"},
+	"CloseRecall":                        {"ctx", "recallId"},
+	"CreateAccount":                      {"ctx", "asset"},
+	"CreateBank_details":                 {"ctx", "asset"},
+	"CreateCustomer":                     {"ctx", "asset"},
+	"CreateDistributor":                  {"ctx", "asset"},
+	"CreateInvoice":                      {"ctx", "asset"},
+	"CreateManufacturer":                 {"ctx", "asset"},
+	"CreateOffer":                        {"ctx", "asset"},
+	"CreateProducts":                     {"ctx", "manufacturerId", "rawMaterialConsumed", "productsCreated"},
+	"CreatePurchaseOrder":                {"ctx", "asset"},
+	"CreateRetailer":                     {"ctx", "asset"},
+	"CreateSupplier":                     {"ctx", "asset"},
+	"DeleteAccount":                      {"ctx", "id"},
+	"DeleteBank_details":                 {"ctx", "id"},
+	"DeleteSupplier":                     {"ctx", "id"},
+	"ExecuteQuery":                       {"ctx", "inputQuery"},
+	"FetchRawMaterial":                   {"ctx", "supplierId", "rawMaterialSupply"},
+	"GetAccountById":                     {"ctx", "id"},
+	"GetAccountByRange":                  {"ctx", "startkey", "endKey"},
+	"GetAccountHistoryById":              {"ctx", "id"},
+	"GetAccountSchema":                   {},
+	"GetBank_detailsById":                {"ctx", "id"},
+	"GetBank_detailsByRange":             {"ctx", "startkey", "endKey"},
+	"GetBank_detailsHistoryById":         {"ctx", "id"},
+	"GetBank_detailsSchema":              {},
+	"GetConfig":                          {"ctx"},
+	"GetCustomerById":                    {"ctx", "id"},
+	"GetCustomerSchema":                  {},
+	"GetDistributionReport":              {"ctx", "groupBy", "filter"},
+	"GetDistributorById":                 {"ctx", "id"},
+	"GetDistributorSchema":               {},
+	"GetInventoryBalance":                {"ctx", "participantId"},
+	"GetInventoryMovementById":           {"ctx", "id"},
+	"GetInventoryMovementSchema":         {},
+	"GetInventoryMovementsByParticipant": {"ctx", "participantId"},
+	"GetInvoiceById":                     {"ctx", "id"},
+	"GetInvoiceSchema":                   {},
+	"GetInvoicesByParticipant":           {"ctx", "participantId"},
+	"GetLicenseRenewalSchema":            {},
+	"GetLicenseRenewalsBySupplier":       {"ctx", "supplierId"},
+	"GetLicensesExpiringWithin":          {"ctx", "days"},
+	"GetManufacturerById":                {"ctx", "id"},
+	"GetManufacturerHistoryById":         {"ctx", "id"},
+	"GetManufacturerProductsReport":      {"ctx", "groupBy", "filter"},
+	"GetManufacturerSchema":              {},
+	"GetMetadata":                        {},
+	"GetOfferById":                       {"ctx", "id"},
+	"GetOfferRedemptionSchema":           {},
+	"GetOfferSchema":                     {},
+	"GetOfferUsageByRetailer":            {"ctx", "retailerId"},
+	"GetPartyBalance":                    {"ctx", "participantId"},
+	"GetPaymentSchema":                   {},
+	"GetPaymentsByInvoice":               {"ctx", "invoiceId"},
+	"GetProductBatchById":                {"ctx", "id"},
+	"GetProductBatchSchema":              {},
+	"GetPurchaseOrderById":               {"ctx", "id"},
+	"GetPurchaseOrderSchema":             {},
+	"GetRawMaterialFromSupplier":         {"ctx", "manufacturerId", "supplierId", "rawMaterialSupply"},
+	"GetRawMaterialLotById":              {"ctx", "id"},
+	"GetRawMaterialLotSchema":            {},
+	"GetRawMaterialReport":               {"ctx", "groupBy", "filter"},
+	"GetRecallById":                      {"ctx", "id"},
+	"GetRecallSchema":                    {},
+	"GetRecallsByParticipant":            {"ctx", "participantId"},
+	"GetReport":                          {"ctx", "spec"},
+	"GetRetailSalesReport":               {"ctx", "groupBy", "filter"},
+	"GetRetailerById":                    {"ctx", "id"},
+	"GetRetailerSchema":                  {},
+	"GetSaleReceiptById":                 {"ctx", "id"},
+	"GetSaleReceiptSchema":               {},
+	"GetSaleReceiptsByParticipant":       {"ctx", "participantId"},
+	"GetShipmentById":                    {"ctx", "id"},
+	"GetShipmentCustodyChain":            {"ctx", "shipmentId"},
+	"GetShipmentSchema":                  {},
+	"GetShipmentsByDistributor":          {"ctx", "distributorId"},
+	"GetSupplierById":                    {"ctx", "id"},
+	"GetSupplierByRange":                 {"ctx", "startkey", "endKey"},
+	"GetSupplierHistoryById":             {"ctx", "id"},
+	"GetSupplierPage":                    {"ctx", "pageSize", "bookmark"},
+	"GetSupplierSchema":                  {},
+	"GetTransitionHistoryById":           {"ctx", "id"},
+	"Init":                               {"ctx", "config"},
+	"QueryAssets":                        {"ctx", "spec"},
+	"RaiseRecall":                        {"ctx", "asset"},
+	"ReceivePurchaseOrder":               {"ctx", "id"},
+	"ReceiveShipment":                    {"ctx", "shipmentId"},
+	"RecordPayment":                      {"ctx", "asset"},
+	"RecordSale":                         {"ctx", "retailerId", "customerId", "quantity"},
+	"RenewSupplierLicense":               {"ctx", "supplierId", "license", "expiryDate"},
+	"SellWithOffer":                      {"ctx", "retailerId", "customerId", "quantity", "unitPrice", "offerId"},
+	"SendProductsToDistribution":         {"ctx", "asset"},
+	"ShipPurchaseOrder":                  {"ctx", "id"},
+	"SubmitPurchaseOrder":                {"ctx", "id"},
+	"TraceBackward":                      {"ctx", "customerId"},
+	"TraceForward":                       {"ctx", "lotId"},
+	"UpdateAccount":                      {"ctx", "asset"},
+	"UpdateBank_details":                 {"ctx", "asset"},
+	"UpdateConfig":                       {"ctx", "config"},
+	"UpdateDistributor":                  {"ctx", "asset"},
+	"UpdateSupplier":                     {"ctx", "asset"},
+	"WithdrawOffer":                      {"ctx", "offerId"},
+}
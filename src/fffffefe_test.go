@@ -6,11 +6,29 @@
 package src
 
 import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"example.com/fffffefe/cmd/methodparams/params"
 	"example.com/fffffefe/lib/chaincode/chaincodetest"
+	"example.com/fffffefe/lib/model"
 	"example.com/fffffefe/lib/util"
+	"example.com/fffffefe/lib/util/date"
+	"example.com/fffffefe/lib/util/decimal"
+	"example.com/fffffefe/lib/util/validators"
+	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
 )
 
 func TestControllerMethods(t *testing.T) {
@@ -18,6 +36,7 @@ func TestControllerMethods(t *testing.T) {
 	mockStub := shimtest.NewMockStub("Test Stub", mockchaincode)
 	controller := new(Controller)
 	util.Stub = mockStub
+	util.ChaincodeName = "fffffefe"
 
 	/**
 	 * t - testing interface
@@ -45,17 +64,1292 @@ func TestControllerMethods(t *testing.T) {
 	 * arguments - should be as required by the controller method.
 	 */
 
-	//Substitute methodName with the required method's name to test successfully
-	t.Run("test method: methodName", func(t *testing.T) {
+	t.Run("test method: Init", func(t *testing.T) {
 		mockStub.MockTransactionStart("Txid1")
-				
-		//Create proper arguments here and pass in the call below.
+		setCreator(mockStub, "Org1MSP")
 
-		res, err := controller.methodName("method arguments")
+		config := `{"Features":{"SoftDelete":true},"Seed":{"Supplier":[` + supplierJSON("s") + `]}}`
+		res, err := controller.Init(util.CurrentContext(), config)
 		if err != nil {
-			t.Errorf("methodName fail. Error %s \n", err.Error())
+			t.Errorf("Init fail. Error %s \n", err.Error())
 			t.FailNow()
 		}
-		t.Logf("methodName success. Result: %v \n", res)
+		cfg := res.(*model.Config)
+		if len(cfg.AdminMSPs) != 1 || cfg.AdminMSPs[0] != "Org1MSP" {
+			t.Errorf("Init fail. Expected creator MSP to become admin, got %v \n", cfg.AdminMSPs)
+		}
+		if _, err := controller.GetSupplierById(util.CurrentContext(), "s"); err != nil {
+			t.Errorf("Init fail. Seed supplier not created. Error %s \n", err.Error())
+		}
+		if _, err := controller.Init(util.CurrentContext(), `{"Seed":{"Unknown":[{}]}}`); err == nil {
+			t.Errorf("Init fail. Unknown seed asset type accepted \n")
+		}
+
+		// a fresh deploy initialised without a config gets the creator MSP as admin too
+		freshStub := shimtest.NewMockStub("Fresh Stub", mockchaincode)
+		freshStub.MockTransactionStart("Txid1")
+		if _, err := controller.Init(util.NewTxContext(freshStub), ""); err == nil {
+			t.Errorf("Init fail. Config without admin saved for a creator without MSP \n")
+		}
+		setCreator(freshStub, "Org1MSP")
+		fresh, err := controller.Init(util.NewTxContext(freshStub), "")
+		if err != nil || len(fresh.(*model.Config).AdminMSPs) != 1 || fresh.(*model.Config).AdminMSPs[0] != "Org1MSP" {
+			t.Errorf("Init fail. Init without config gave admins %v Error %v \n", fresh, err)
+		}
+
+		// once initialised, only an admin may run Init again
+		freshStub.MockTransactionStart("Txid1b")
+		setCreator(freshStub, "EvilMSP")
+		if _, err := controller.Init(util.NewTxContext(freshStub), `{"AdminMSPs":["EvilMSP"]}`); err == nil || !strings.HasPrefix(err.Error(), "Access denied") {
+			t.Errorf("Init fail. Non admin replaced the config. Error %v \n", err)
+		}
+		if _, err := controller.Init(util.NewTxContext(freshStub), ""); err == nil {
+			t.Errorf("Init fail. Non admin re-ran Init \n")
+		}
+		setCreator(freshStub, "Org1MSP")
+		rerun, err := controller.Init(util.NewTxContext(freshStub), `{"RegulatorMSPs":["Org2MSP"]}`)
+		if err != nil || len(rerun.(*model.Config).AdminMSPs) != 1 || rerun.(*model.Config).AdminMSPs[0] != "Org1MSP" {
+			t.Errorf("Init fail. Admin re-run without admins gave %v Error %v \n", rerun, err)
+		}
+		t.Logf("Init success. Result: %v \n", res)
+	})
+
+	t.Run("test method: UpdateConfig", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid2")
+		setCreator(mockStub, "Org2MSP")
+		if _, err := controller.UpdateConfig(util.CurrentContext(), `{"AdminMSPs":["Org2MSP"]}`); err == nil {
+			t.Errorf("UpdateConfig fail. Non admin was allowed to update config \n")
+		}
+
+		setCreator(mockStub, "Org1MSP")
+		res, err := controller.UpdateConfig(util.CurrentContext(), `{"AdminMSPs":["Org1MSP","Org2MSP"],"Validation":"lenient"}`)
+		if err != nil {
+			t.Errorf("UpdateConfig fail. Error %s \n", err.Error())
+			t.FailNow()
+		}
+		cfg, err := controller.GetConfig(util.CurrentContext())
+		if err != nil || cfg.Validation != model.ValidationLenient || len(cfg.AdminMSPs) != 2 {
+			t.Errorf("UpdateConfig fail. Config not persisted: %v %v \n", cfg, err)
+		}
+		t.Logf("UpdateConfig success. Result: %v \n", res)
+	})
+
+	t.Run("test method: inventory movements", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid3")
+		var manufacturer Manufacturer
+		if err := json.Unmarshal([]byte(manufacturerJSON("m1")), &manufacturer); err != nil {
+			t.FailNow()
+		}
+		if _, err := controller.CreateManufacturer(util.CurrentContext(), manufacturer); err != nil {
+			t.Errorf("CreateManufacturer fail. Error %s \n", err.Error())
+			t.FailNow()
+		}
+		if _, err := controller.FetchRawMaterial(util.CurrentContext(), "s", 3); err != nil {
+			t.Errorf("FetchRawMaterial fail. Error %s \n", err.Error())
+		}
+
+		mockStub.MockTransactionStart("Txid4")
+		if _, err := controller.GetRawMaterialFromSupplier(util.CurrentContext(), "m1", "s", 10); err == nil {
+			t.Errorf("GetRawMaterialFromSupplier fail. Supplier balance went negative \n")
+		}
+		if _, err := controller.GetRawMaterialFromSupplier(util.CurrentContext(), "m1", "s", 6); err != nil {
+			t.Errorf("GetRawMaterialFromSupplier fail. Error %s \n", err.Error())
+		}
+
+		mockStub.MockTransactionStart("Txid5")
+		if _, err := controller.CreateProducts(util.CurrentContext(), "m1", 4, 20); err != nil {
+			t.Errorf("CreateProducts fail. Error %s \n", err.Error())
+		}
+		res, err := controller.GetInventoryBalance(util.CurrentContext(), "m1")
+		if err != nil {
+			t.Errorf("GetInventoryBalance fail. Error %s \n", err.Error())
+			t.FailNow()
+		}
+		for _, balance := range res {
+			if !balance.Consistent {
+				t.Errorf("GetInventoryBalance fail. Inconsistent balance %v \n", balance)
+			}
+		}
+		if movements, _ := controller.GetInventoryMovementsByParticipant(util.CurrentContext(), "s"); len(movements) != 3 {
+			t.Errorf("GetInventoryMovementsByParticipant fail. Expected 3 movements, got %v \n", movements)
+		}
+
+		supplier, _ := controller.GetSupplierById(util.CurrentContext(), "s")
+		supplier.RawMaterialAvailable = 100
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), supplier); err == nil {
+			t.Errorf("UpdateSupplier fail. Balance overwritten without a movement \n")
+		}
+		t.Logf("Inventory success. Result: %v \n", res)
 	})
+
+	t.Run("test method: purchase order lifecycle", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid6")
+		var retailer Retailer
+		var distributor Distributor
+		mustUnmarshal(t, withOwner(retailerJSON("r1"), "Org3MSP"), &retailer)
+		mustUnmarshal(t, distributorJSON("d1", 10), &distributor)
+		setCreator(mockStub, "Org2MSP")
+		if _, err := controller.CreateRetailer(util.CurrentContext(), retailer); err == nil {
+			t.Errorf("CreateRetailer fail. Retailer created on behalf of another organisation \n")
+		}
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.CreateRetailer(util.CurrentContext(), retailer); err != nil {
+			t.Fatalf("CreateRetailer fail. Error %s \n", err.Error())
+		}
+		setCreator(mockStub, "Org2MSP")
+		if _, err := controller.CreateDistributor(util.CurrentContext(), distributor); err != nil {
+			t.Fatalf("CreateDistributor fail. Error %s \n", err.Error())
+		}
+		if created, err := controller.GetDistributorById(util.CurrentContext(), "d1"); err != nil || created.OwnerMSP != "Org2MSP" {
+			t.Errorf("CreateDistributor fail. Owner not taken from the caller. Result %v Error %v \n", created, err)
+		}
+		distributor.OwnerMSP = "Org3MSP"
+		if _, err := controller.UpdateDistributor(util.CurrentContext(), distributor); err == nil {
+			t.Errorf("UpdateDistributor fail. OwnerMSP changed \n")
+		}
+
+		var order PurchaseOrder
+		mustUnmarshal(t, `{"PurchaseOrderId":"po1","Buyer":"r1","Seller":"d1","Lines":[{"Product":"widget","Quantity":4}]}`, &order)
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.CreatePurchaseOrder(util.CurrentContext(), order); err != nil {
+			t.Fatalf("CreatePurchaseOrder fail. Error %s \n", err.Error())
+		}
+		if _, err := controller.SubmitPurchaseOrder(util.CurrentContext(), "po1"); err != nil {
+			t.Fatalf("SubmitPurchaseOrder fail. Error %s \n", err.Error())
+		}
+		if _, err := controller.AcceptPurchaseOrder(util.CurrentContext(), "po1"); err == nil {
+			t.Errorf("AcceptPurchaseOrder fail. Buyer was allowed to accept its own order \n")
+		}
+
+		mockStub.MockTransactionStart("Txid7")
+		setCreator(mockStub, "Org2MSP")
+		if _, err := controller.AcceptPurchaseOrder(util.CurrentContext(), "po1"); err != nil {
+			t.Fatalf("AcceptPurchaseOrder fail. Error %s \n", err.Error())
+		}
+		if _, err := controller.ShipPurchaseOrder(util.CurrentContext(), "po1"); err != nil {
+			t.Fatalf("ShipPurchaseOrder fail. Error %s \n", err.Error())
+		}
+		if _, err := controller.CancelPurchaseOrder(util.CurrentContext(), "po1", "too late"); err == nil {
+			t.Errorf("CancelPurchaseOrder fail. Shipped order was cancelled \n")
+		}
+
+		mockStub.MockTransactionStart("Txid8")
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.ReceivePurchaseOrder(util.CurrentContext(), "po1"); err != nil {
+			t.Fatalf("ReceivePurchaseOrder fail. Error %s \n", err.Error())
+		}
+		res, err := controller.ClosePurchaseOrder(util.CurrentContext(), "po1")
+		if err != nil {
+			t.Fatalf("ClosePurchaseOrder fail. Error %s \n", err.Error())
+		}
+		retailer, _ = controller.GetRetailerById(util.CurrentContext(), "r1")
+		distributor, _ = controller.GetDistributorById(util.CurrentContext(), "d1")
+		if retailer.ProductsOrdered != 0 || retailer.ProductsAvailable != 4 {
+			t.Errorf("Purchase order fail. Unexpected retailer counters %v \n", retailer)
+		}
+		if distributor.ProductsShipped != 7 || distributor.ProductsToBeShipped != 6 {
+			t.Errorf("Purchase order fail. Unexpected distributor counters %v \n", distributor)
+		}
+		t.Logf("Purchase order success. Result: %v \n", res)
+	})
+
+	t.Run("test method: status state machines", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid9")
+		setCreator(mockStub, "Org3MSP")
+		supplier, _ := controller.GetSupplierById(util.CurrentContext(), "s")
+		supplier.Status = SupplierSuspended
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), supplier); err == nil {
+			t.Errorf("UpdateSupplier fail. Supplier suspended by an organisation not owning it \n")
+		}
+		setCreator(mockStub, "Org1MSP")
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), supplier); err != nil {
+			t.Fatalf("UpdateSupplier fail. Error %s \n", err.Error())
+		}
+		supplier.Status = SupplierActive
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), supplier); err != nil {
+			t.Fatalf("UpdateSupplier fail. Error %s \n", err.Error())
+		}
+		supplier.Status = SupplierSuspended
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), supplier); err != nil {
+			t.Fatalf("UpdateSupplier fail. Error %s \n", err.Error())
+		}
+
+		mockStub.MockTransactionStart("Txid10")
+		setCreator(mockStub, "Org3MSP")
+		supplier.Status = SupplierActive
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), supplier); err == nil {
+			t.Errorf("UpdateSupplier fail. Non admin reinstated a supplier \n")
+		}
+		supplier.Status = SupplierRevoked
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), supplier); err == nil {
+			t.Errorf("UpdateSupplier fail. Non admin revoked a supplier \n")
+		}
+		setCreator(mockStub, "Org1MSP")
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), supplier); err != nil {
+			t.Fatalf("UpdateSupplier fail. Error %s \n", err.Error())
+		}
+
+		mockStub.MockTransactionStart("Txid11")
+		supplier.Status = SupplierActive
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), supplier); err == nil {
+			t.Errorf("UpdateSupplier fail. Revoked supplier was reactivated \n")
+		}
+		res, err := controller.GetTransitionHistoryById(util.CurrentContext(), "s")
+		if err != nil || len(res) != 4 || res[1].To != SupplierActive || res[2].Sequence != 2 || res[3].To != SupplierRevoked {
+			t.Errorf("GetTransitionHistoryById fail. Result %v Error %v \n", res, err)
+		}
+
+		distributor, _ := controller.GetDistributorById(util.CurrentContext(), "d1")
+		distributor.ShipmentStatus = ShipmentInTransit
+		setCreator(mockStub, "EvilMSP")
+		if _, err := controller.UpdateDistributor(util.CurrentContext(), distributor); err == nil {
+			t.Errorf("UpdateDistributor fail. Distributor updated by an organisation not owning it \n")
+		}
+		setCreator(mockStub, "Org2MSP")
+		distributor.ShipmentStatus = ShipmentDelivered
+		if _, err := controller.UpdateDistributor(util.CurrentContext(), distributor); err == nil {
+			t.Errorf("UpdateDistributor fail. Pending shipment was delivered without transit \n")
+		}
+		distributor.ShipmentStatus = ShipmentInTransit
+		if _, err := controller.UpdateDistributor(util.CurrentContext(), distributor); err != nil {
+			t.Errorf("UpdateDistributor fail. Error %s \n", err.Error())
+		}
+		t.Logf("State machine success. Result: %v \n", res)
+	})
+
+	t.Run("test method: shipment custody chain", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid12")
+		setCreator(mockStub, "Org1MSP")
+		var shipment Shipment
+		mustUnmarshal(t, `{"ShipmentId":"sh1","Manufacturer":"m1","Distributor":"d1","Retailer":"r1","Quantity":5}`, &shipment)
+		if _, err := controller.SendProductsToDistribution(util.CurrentContext(), shipment); err != nil {
+			t.Fatalf("SendProductsToDistribution fail. Error %s \n", err.Error())
+		}
+
+		mockStub.MockTransactionStart("Txid13")
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.ReceiveShipment(util.CurrentContext(), "sh1"); err == nil {
+			t.Errorf("ReceiveShipment fail. Retailer signed for the distributor \n")
+		}
+		setCreator(mockStub, "Org2MSP")
+		if _, err := controller.ReceiveShipment(util.CurrentContext(), "sh1"); err != nil {
+			t.Fatalf("ReceiveShipment fail. Error %s \n", err.Error())
+		}
+
+		mockStub.MockTransactionStart("Txid14")
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.ReceiveShipment(util.CurrentContext(), "sh1"); err != nil {
+			t.Fatalf("ReceiveShipment fail. Error %s \n", err.Error())
+		}
+		chain, err := controller.GetShipmentCustodyChain(util.CurrentContext(), "sh1")
+		if err != nil || len(chain) != 3 || chain[2].SignedBy != "Org3MSP" {
+			t.Errorf("GetShipmentCustodyChain fail. Result %v Error %v \n", chain, err)
+		}
+		res, err := controller.GetShipmentsByDistributor(util.CurrentContext(), "d1")
+		if err != nil || len(res) != 1 {
+			t.Errorf("GetShipmentsByDistributor fail. Result %v Error %v \n", res, err)
+		}
+		if retailer, _ := controller.GetRetailerById(util.CurrentContext(), "r1"); retailer.ProductsAvailable != 9 {
+			t.Errorf("ReceiveShipment fail. Retailer holds %d products \n", retailer.ProductsAvailable)
+		}
+		t.Logf("Shipment success. Result: %v \n", res)
+	})
+
+	t.Run("test method: lot traceability", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid15")
+		setCreator(mockStub, "Org1MSP")
+		var supplier Supplier
+		var manufacturer Manufacturer
+		var retailer Retailer
+		mustUnmarshal(t, supplierJSON("t"), &supplier)
+		mustUnmarshal(t, manufacturerJSON("m2"), &manufacturer)
+		mustUnmarshal(t, withOwner(retailerJSON("r2"), "Org3MSP"), &retailer)
+		for _, err := range []error{
+			createParticipant(util.CurrentContext(), &supplier),
+			createParticipant(util.CurrentContext(), &manufacturer),
+			createParticipant(util.CurrentContext(), &retailer),
+		} {
+			if err != nil {
+				t.Fatalf("Create participant fail. Error %s \n", err.Error())
+			}
+		}
+
+		mockStub.MockTransactionStart("Txid16")
+		res, err := controller.GetRawMaterialFromSupplier(util.CurrentContext(), "m2", "t", 4)
+		if err != nil {
+			t.Fatalf("GetRawMaterialFromSupplier fail. Error %s \n", err.Error())
+		}
+		lotId := res.([]InventoryMovement)[0].Lots[0].LotId
+		if _, err := controller.CreateProducts(util.CurrentContext(), "m2", 4, 10); err != nil {
+			t.Fatalf("CreateProducts fail. Error %s \n", err.Error())
+		}
+
+		mockStub.MockTransactionStart("Txid17")
+		var shipment Shipment
+		mustUnmarshal(t, `{"ShipmentId":"sh2","Manufacturer":"m2","Distributor":"d1","Retailer":"r2","Quantity":10}`, &shipment)
+		if _, err := controller.SendProductsToDistribution(util.CurrentContext(), shipment); err != nil {
+			t.Fatalf("SendProductsToDistribution fail. Error %s \n", err.Error())
+		}
+		setCreator(mockStub, "Org2MSP")
+		if _, err := controller.ReceiveShipment(util.CurrentContext(), "sh2"); err != nil {
+			t.Fatalf("ReceiveShipment fail. Error %s \n", err.Error())
+		}
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.ReceiveShipment(util.CurrentContext(), "sh2"); err != nil {
+			t.Fatalf("ReceiveShipment fail. Error %s \n", err.Error())
+		}
+
+		mockStub.MockTransactionStart("Txid18")
+		batch := newInventoryBatch(util.CurrentContext())
+		if err := batch.sell("r2", "c-buyer", 3, "sold"); err != nil {
+			t.Fatalf("Sale fail. Error %s \n", err.Error())
+		}
+		if _, err := batch.commit(); err != nil {
+			t.Fatalf("Sale fail. Error %s \n", err.Error())
+		}
+
+		backward, err := controller.TraceBackward(util.CurrentContext(), "c-buyer")
+		if err != nil || len(backward.ProductBatches) != 1 || len(backward.RawMaterialLots) != 1 || backward.RawMaterialLots[0].Supplier != "t" {
+			t.Errorf("TraceBackward fail. Result %v Error %v \n", backward, err)
+		}
+		forward, err := controller.TraceForward(util.CurrentContext(), lotId)
+		if err != nil || len(forward.ProductBatches) != 1 {
+			t.Fatalf("TraceForward fail. Result %v Error %v \n", forward, err)
+		}
+		productBatch := forward.ProductBatches[0]
+		if productBatch.Holdings["r2"] != 7 || len(productBatch.Sales) != 1 || productBatch.Sales[0].Customer != "c-buyer" {
+			t.Errorf("TraceForward fail. Unexpected batch %v \n", productBatch)
+		}
+		t.Logf("Traceability success. Result: %v \n", forward)
+	})
+
+	t.Run("test method: product recall", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid19")
+		backward, err := controller.TraceBackward(util.CurrentContext(), "c-buyer")
+		if err != nil || len(backward.RawMaterialLots) != 1 {
+			t.Fatalf("TraceBackward fail. Result %v Error %v \n", backward, err)
+		}
+		lotId := backward.RawMaterialLots[0].LotId
+		setCreator(mockStub, "Org3MSP")
+		var recall Recall
+		mustUnmarshal(t, `{"RecallId":"rc1","Reason":"contaminated","RawMaterialLots":["`+lotId+`"]}`, &recall)
+		if _, err := controller.RaiseRecall(util.CurrentContext(), recall); err == nil {
+			t.Errorf("RaiseRecall fail. Caller without regulator MSP was allowed to raise a recall \n")
+		}
+
+		setCreator(mockStub, "Org1MSP")
+		recall.RaisedBy = "m2"
+		res, err := controller.RaiseRecall(util.CurrentContext(), recall)
+		if err != nil {
+			t.Fatalf("RaiseRecall fail. Error %s \n", err.Error())
+		}
+		raised := res.(*Recall)
+		if raised.Status != RecallOpen || len(raised.AffectedBatches) != 1 || len(raised.AffectedParties) != 1 ||
+			raised.AffectedParties[0].ParticipantId != "r2" || raised.AffectedParties[0].Quantity != 7 {
+			t.Errorf("RaiseRecall fail. Unexpected recall %v \n", raised)
+		}
+
+		mockStub.MockTransactionStart("Txid20")
+		batch := newInventoryBatch(util.CurrentContext())
+		if err := batch.sell("r2", "c-buyer", 1, "sold"); err == nil {
+			t.Errorf("RaiseRecall fail. Recalled stock was sold \n")
+		}
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.CloseRecall(util.CurrentContext(), "rc1"); err == nil {
+			t.Errorf("CloseRecall fail. Recall closed by an organisation which did not raise it \n")
+		}
+		if _, err := controller.AcknowledgeRecall(util.CurrentContext(), "rc1", "r2"); err != nil {
+			t.Errorf("AcknowledgeRecall fail. Error %s \n", err.Error())
+		}
+		recalls, err := controller.GetRecallsByParticipant(util.CurrentContext(), "r2")
+		if err != nil || len(recalls) != 1 || !recalls[0].AffectedParties[0].Acknowledged {
+			t.Errorf("GetRecallsByParticipant fail. Result %v Error %v \n", recalls, err)
+		}
+		setCreator(mockStub, "Org1MSP")
+		if _, err := controller.CloseRecall(util.CurrentContext(), "rc1"); err != nil {
+			t.Errorf("CloseRecall fail. Error %s \n", err.Error())
+		}
+		t.Logf("Recall success. Result: %v \n", raised)
+	})
+
+	t.Run("test method: supplier licence", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid21")
+		setCreator(mockStub, "Org1MSP")
+		soon := time.Now().UTC().AddDate(0, 0, 10).Format(date.CustomDateLayout)
+		var expired, expiring Supplier
+		mustUnmarshal(t, strings.Replace(supplierJSON("u"), `"ExpiryDate":"2099-12-31","Active"`, `"ExpiryDate":"2020-05-30","Active"`, 1), &expired)
+		mustUnmarshal(t, strings.Replace(supplierJSON("v"), `"ExpiryDate":"2099-12-31","Active"`, `"ExpiryDate":"`+soon+`","Active"`, 1), &expiring)
+		if err := createParticipant(util.CurrentContext(), &expired); err != nil {
+			t.Fatalf("Create participant fail. Error %s \n", err.Error())
+		}
+		if err := createParticipant(util.CurrentContext(), &expiring); err != nil {
+			t.Fatalf("Create participant fail. Error %s \n", err.Error())
+		}
+
+		mockStub.MockTransactionStart("Txid22")
+		if _, err := controller.FetchRawMaterial(util.CurrentContext(), "u", 1); err == nil {
+			t.Errorf("FetchRawMaterial fail. Supplier with an expired licence was accepted \n")
+		}
+		listed, err := controller.GetLicensesExpiringWithin(util.CurrentContext(), 30)
+		if err != nil || len(listed) != 1 || listed[0].SupplierId != "v" {
+			t.Errorf("GetLicensesExpiringWithin fail. Result %v Error %v \n", listed, err)
+		}
+
+		var owned Supplier
+		mustUnmarshal(t, strings.Replace(supplierJSON("w"), `"ExpiryDate":"2099-12-31","Active"`, `"ExpiryDate":"2020-05-30","Active"`, 1), &owned)
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.CreateSupplier(util.CurrentContext(), owned); err != nil {
+			t.Fatalf("CreateSupplier fail. Error %s \n", err.Error())
+		}
+		mockStub.MockTransactionStart("Txid22b")
+		owned.OwnerMSP = "Org3MSP"
+		owned.ExpiryDate = date.Date{Time: time.Date(2099, 6, 30, 0, 0, 0, 0, time.UTC)}
+		setCreator(mockStub, "EvilMSP")
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), owned); err == nil {
+			t.Errorf("UpdateSupplier fail. Supplier updated by an organisation not owning it \n")
+		}
+		if _, err := controller.DeleteSupplier(util.CurrentContext(), "w"); err == nil {
+			t.Errorf("DeleteSupplier fail. Supplier deleted by an organisation not owning it \n")
+		}
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.UpdateSupplier(util.CurrentContext(), owned); err == nil {
+			t.Errorf("UpdateSupplier fail. Licence extended outside RenewSupplierLicense \n")
+		}
+		if _, err := controller.FetchRawMaterial(util.CurrentContext(), "w", 1); err == nil {
+			t.Errorf("FetchRawMaterial fail. Supplier with an expired licence was accepted \n")
+		}
+		if _, err := controller.DeleteSupplier(util.CurrentContext(), "w"); err != nil {
+			t.Errorf("DeleteSupplier fail. Error %s \n", err.Error())
+		}
+		if _, err := controller.RenewSupplierLicense(util.CurrentContext(), "u", "lic2", "2099-06-30"); err == nil {
+			t.Errorf("RenewSupplierLicense fail. Renewal by a non admin was accepted \n")
+		}
+		setCreator(mockStub, "Org1MSP")
+		if _, err := controller.RenewSupplierLicense(util.CurrentContext(), "u", "lic2", "2099-06-30"); err != nil {
+			t.Fatalf("RenewSupplierLicense fail. Error %s \n", err.Error())
+		}
+		if _, err := controller.FetchRawMaterial(util.CurrentContext(), "u", 1); err != nil {
+			t.Errorf("FetchRawMaterial fail. Error %s \n", err.Error())
+		}
+		renewals, err := controller.GetLicenseRenewalsBySupplier(util.CurrentContext(), "u")
+		if err != nil || len(renewals) != 1 || renewals[0].PreviousLicense != "lic1" || renewals[0].License != "lic2" {
+			t.Errorf("GetLicenseRenewalsBySupplier fail. Result %v Error %v \n", renewals, err)
+		}
+		t.Logf("Licence success. Result: %v \n", renewals)
+	})
+
+	t.Run("test method: invoices and payments", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid23")
+		setCreator(mockStub, "Org1MSP")
+		res, err := controller.GetRawMaterialFromSupplier(util.CurrentContext(), "m2", "u", 2)
+		if err != nil {
+			t.Fatalf("GetRawMaterialFromSupplier fail. Error %s \n", err.Error())
+		}
+		movementId := res.([]InventoryMovement)[0].MovementId
+		var invoice Invoice
+		mustUnmarshal(t, `{"InvoiceId":"inv1","MovementId":"`+movementId+`","UnitPrice":"2.50"}`, &invoice)
+		res, err = controller.CreateInvoice(util.CurrentContext(), invoice)
+		if err != nil {
+			t.Fatalf("CreateInvoice fail. Error %s \n", err.Error())
+		}
+		if issued := res.(*Invoice); issued.Seller != "u" || issued.Buyer != "m2" || !issued.Amount.Equal(decimal.MustParse("5")) || issued.Status != InvoiceIssued {
+			t.Errorf("CreateInvoice fail. Unexpected invoice %v \n", issued)
+		}
+		invoice.InvoiceId = "inv2"
+		if _, err := controller.CreateInvoice(util.CurrentContext(), invoice); err == nil {
+			t.Errorf("CreateInvoice fail. Movement billed twice \n")
+		}
+
+		mockStub.MockTransactionStart("Txid24")
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.RecordPayment(util.CurrentContext(), Payment{PaymentId: "pay1", InvoiceId: "inv1", Amount: decimal.MustParse("2.00")}); err == nil {
+			t.Errorf("RecordPayment fail. Payment by a non party was accepted \n")
+		}
+		setCreator(mockStub, "Org1MSP")
+		if _, err := controller.RecordPayment(util.CurrentContext(), Payment{PaymentId: "pay1", InvoiceId: "inv1", Amount: decimal.MustParse("2.00")}); err != nil {
+			t.Fatalf("RecordPayment fail. Error %s \n", err.Error())
+		}
+		balance, err := controller.GetPartyBalance(util.CurrentContext(), "u")
+		if err != nil || balance.Receivable.String() != "3.00" || len(balance.Counterparties) != 1 || balance.Counterparties[0].Counterparty != "m2" {
+			t.Errorf("GetPartyBalance fail. Result %v Error %v \n", balance, err)
+		}
+
+		mockStub.MockTransactionStart("Txid25")
+		if _, err := controller.RecordPayment(util.CurrentContext(), Payment{PaymentId: "pay2", InvoiceId: "inv1", Amount: decimal.MustParse("3.01")}); err == nil {
+			t.Errorf("RecordPayment fail. Overpayment was accepted \n")
+		}
+		if _, err := controller.RecordPayment(util.CurrentContext(), Payment{PaymentId: "pay2", InvoiceId: "inv1", Amount: decimal.MustParse("3")}); err != nil {
+			t.Fatalf("RecordPayment fail. Error %s \n", err.Error())
+		}
+		paid, err := controller.GetInvoiceById(util.CurrentContext(), "inv1")
+		if err != nil || paid.Status != InvoicePaid || paid.Paid.String() != "5.00" {
+			t.Errorf("RecordPayment fail. Invoice %v Error %v \n", paid, err)
+		}
+		if _, err := controller.CancelInvoice(util.CurrentContext(), "inv1"); err == nil {
+			t.Errorf("CancelInvoice fail. Paid invoice was cancelled \n")
+		}
+		payments, err := controller.GetPaymentsByInvoice(util.CurrentContext(), "inv1")
+		if err != nil || len(payments) != 2 || payments[0].PayerAccount != "bd01" || payments[0].PayeeAccount != "ac01" {
+			t.Errorf("GetPaymentsByInvoice fail. Result %v Error %v \n", payments, err)
+		}
+		t.Logf("Invoice success. Result: %v \n", paid)
+	})
+
+	t.Run("test method: decimal fields", func(t *testing.T) {
+		price, err := decimal.Parse("0.10")
+		if err != nil {
+			t.Fatalf("Decimal parse fail. Error %s \n", err.Error())
+		}
+		if sum := price.Add(decimal.MustParse("0.2")); sum.String() != "0.30" {
+			t.Errorf("Decimal add fail. Result %s \n", sum.String())
+		}
+		if third, _ := decimal.NewFromInt(1).Div(decimal.NewFromInt(3), 4); third.String() != "0.3333" {
+			t.Errorf("Decimal div fail. Result %s \n", third.String())
+		}
+		if rounded := decimal.MustParse("-2.345").Rescale(2); rounded.String() != "-2.35" {
+			t.Errorf("Decimal rescale fail. Result %s \n", rounded.String())
+		}
+		var invoice Invoice
+		mustUnmarshal(t, `{"InvoiceId":"inv3","MovementId":"m","UnitPrice":12.5}`, &invoice)
+		encoded, _ := json.Marshal(invoice.UnitPrice)
+		if string(encoded) != `"12.5"` {
+			t.Errorf("Decimal marshal fail. Result %s \n", encoded)
+		}
+		if err := validators.ValidateStruct(&invoice); err != nil {
+			t.Errorf("Decimal validation fail. Error %s \n", err.Error())
+		}
+		invoice.UnitPrice = decimal.MustParse("-1")
+		if err := validators.ValidateStruct(&invoice); err == nil {
+			t.Errorf("Decimal validation fail. Negative price accepted \n")
+		}
+		if err := validators.Validate(decimal.MustParse("4.25"), "decimalMin=1,decimalMax=4.2"); err == nil {
+			t.Errorf("Decimal validation fail. Value above max accepted \n")
+		}
+		if err := validators.Validate(decimal.MustParse("4.20"), "decimalMin=1,decimalMax=4.2"); err != nil {
+			t.Errorf("Decimal validation fail. Value within bounds rejected %s \n", err.Error())
+		}
+		if err := validators.Validate("abcde", "min=2,max=4"); err == nil {
+			t.Errorf("Decimal validation fail. Builtin max no longer applies to strings \n")
+		}
+		if err := validators.Validate(decimal.MustParse("1.125"), "scale=2"); err == nil {
+			t.Errorf("Decimal validation fail. Value with too many fractional digits accepted \n")
+		}
+	})
+
+	t.Run("test method: offers", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid26")
+		setCreator(mockStub, "Org3MSP")
+		var customer Customer
+		mustUnmarshal(t, customerJSON("c-loyal"), &customer)
+		if _, err := controller.CreateCustomer(util.CurrentContext(), customer); err != nil {
+			t.Fatalf("CreateCustomer fail. Error %s \n", err.Error())
+		}
+		var offer Offer
+		mustUnmarshal(t, `{"OfferId":"o1","DiscountPercent":"20","MinQuantity":2,"Retailers":["r1"],"MaxUsesPerCustomer":1}`, &offer)
+		if _, err := controller.CreateOffer(util.CurrentContext(), offer); err != nil {
+			t.Fatalf("CreateOffer fail. Error %s \n", err.Error())
+		}
+
+		mockStub.MockTransactionStart("Txid27")
+		if _, err := controller.SellWithOffer(util.CurrentContext(), "r1", "c-loyal", 1, decimal.MustParse("2.50"), "o1"); err == nil {
+			t.Errorf("SellWithOffer fail. Offer applied below its minimum quantity \n")
+		}
+		res, err := controller.SellWithOffer(util.CurrentContext(), "r1", "c-loyal", 2, decimal.MustParse("2.50"), "o1")
+		if err != nil {
+			t.Fatalf("SellWithOffer fail. Error %s \n", err.Error())
+		}
+		if redemption := res.(*OfferRedemption); redemption.Discount.String() != "1.00" || redemption.NetAmount.String() != "4.00" {
+			t.Errorf("SellWithOffer fail. Unexpected redemption %v \n", redemption)
+		}
+		if _, err := controller.SellWithOffer(util.CurrentContext(), "r1", "c-loyal", 2, decimal.MustParse("2.50"), "o1"); err == nil {
+			t.Errorf("SellWithOffer fail. Per customer limit not enforced \n")
+		}
+		if customer, err := controller.GetCustomerById(util.CurrentContext(), "c-loyal"); err != nil || customer.OfferApplied != 1 {
+			t.Errorf("SellWithOffer fail. Offer not recorded on customer %v Error %v \n", customer, err)
+		}
+		usage, err := controller.GetOfferUsageByRetailer(util.CurrentContext(), "r1")
+		if err != nil || len(usage) != 1 || usage[0].Redemptions != 1 || usage[0].Quantity != 2 || usage[0].Discount.String() != "1.00" {
+			t.Errorf("GetOfferUsageByRetailer fail. Result %v Error %v \n", usage, err)
+		}
+		t.Logf("Offer success. Result: %v \n", usage)
+	})
+
+	t.Run("test method: RecordSale", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid28")
+		setCreator(mockStub, "Org1MSP")
+		if _, err := controller.UpdateConfig(util.CurrentContext(), `{"AdminMSPs":["Org1MSP","Org2MSP"],"Validation":"lenient","Features":{"Events":true}}`); err != nil {
+			t.Fatalf("UpdateConfig fail. Error %s \n", err.Error())
+		}
+		before, _ := controller.GetRetailerById(util.CurrentContext(), "r1")
+
+		mockStub.MockTransactionStart("Txid29")
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.RecordSale(util.CurrentContext(), "r1", "c-loyal", before.ProductsAvailable+1); err == nil {
+			t.Errorf("RecordSale fail. Sale beyond the retailer's stock accepted \n")
+		}
+		res, err := controller.RecordSale(util.CurrentContext(), "r1", "c-loyal", 1)
+		if err != nil {
+			t.Fatalf("RecordSale fail. Error %s \n", err.Error())
+		}
+		receipt := res.(*SaleReceipt)
+		after, _ := controller.GetRetailerById(util.CurrentContext(), "r1")
+		customer, _ := controller.GetCustomerById(util.CurrentContext(), "c-loyal")
+		if after.ProductsSold != before.ProductsSold+1 || after.ProductsAvailable != before.ProductsAvailable-1 ||
+			customer.ProductsBought != 3 || !customer.Received || receipt.MovementId == "" {
+			t.Errorf("RecordSale fail. Retailer %v customer %v receipt %v \n", after, customer, receipt)
+		}
+		select {
+		case event := <-mockStub.ChaincodeEventsChannel:
+			if event.EventName != "SaleRecorded" {
+				t.Errorf("RecordSale fail. Unexpected event %s \n", event.EventName)
+			}
+		default:
+			t.Errorf("RecordSale fail. No event emitted \n")
+		}
+		receipts, err := controller.GetSaleReceiptsByParticipant(util.CurrentContext(), "c-loyal")
+		if err != nil || len(receipts) != 2 || receipts[0].OfferId != "o1" {
+			t.Errorf("GetSaleReceiptsByParticipant fail. Result %v Error %v \n", receipts, err)
+		}
+		t.Logf("RecordSale success. Result: %v \n", receipt)
+	})
+
+	t.Run("test method: reports", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid30")
+		supplier, _ := controller.GetSupplierById(util.CurrentContext(), "u")
+		rows, err := controller.GetRawMaterialReport(util.CurrentContext(), nil, map[string]interface{}{"SupplierId": "u"})
+		if err != nil || len(rows) != 1 || rows[0].Count != 1 ||
+			!rows[0].Totals["RawMaterialAvailable"].Equal(decimal.NewFromInt(int64(supplier.RawMaterialAvailable))) {
+			t.Errorf("GetRawMaterialReport fail. Result %v Error %v \n", rows, err)
+		}
+		retailer, _ := controller.GetRetailerById(util.CurrentContext(), "r1")
+		rows, err = controller.GetRetailSalesReport(util.CurrentContext(), nil, map[string]interface{}{"RetailerId": "r1"})
+		if err != nil || len(rows) != 1 || rows[0].Group["RetailerId"] != "r1" ||
+			!rows[0].Totals["ProductsSold"].Equal(decimal.NewFromInt(int64(retailer.ProductsSold))) {
+			t.Errorf("GetRetailSalesReport fail. Result %v Error %v \n", rows, err)
+		}
+		rows, err = controller.GetRetailSalesReport(util.CurrentContext(), []string{"OwnerMSP"}, nil)
+		if err != nil || len(rows) == 0 || rows[len(rows)-1].Group["OwnerMSP"] != "Org3MSP" || rows[len(rows)-1].Count < 2 {
+			t.Errorf("GetRetailSalesReport fail. Grouped result %v Error %v \n", rows, err)
+		}
+		t.Logf("Reports success. Result: %v \n", rows)
+	})
+
+	t.Run("test method: QueryAssets", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid31")
+		spec := model.QuerySpec{
+			AssetType: "Supplier",
+			Filters:   []model.QueryFilter{{Field: "Status", Op: "eq", Value: "active"}, {Field: "Account.License", Op: "in", Value: []string{"ac01"}}},
+			Sort:      []model.QuerySort{{Field: "ExpiryDate", Descending: true}},
+			Fields:    []string{"SupplierId", "ExpiryDate"},
+		}
+		query, err := model.CompileQuery(spec, Supplier{})
+		if err != nil || !strings.Contains(query, `"sort":[{"ExpiryDate":"desc"}]`) || !strings.Contains(query, `"AssetType":"`+util.ChaincodeName+`.Supplier"`) ||
+			!strings.Contains(query, `"Account.License":{"$in":["ac01"]}`) || !strings.Contains(query, `"IsDeleted":{"$ne":true}`) {
+			t.Errorf("CompileQuery fail. Query %s Error %v \n", query, err)
+		}
+		spec.Sort = []model.QuerySort{{Field: "Account.License"}}
+		if _, err := model.CompileQuery(spec, Supplier{}); err == nil {
+			t.Errorf("CompileQuery fail. Sort on an unindexed field accepted \n")
+		}
+		spec.Sort = nil
+		spec.Filters = []model.QueryFilter{{Field: "$or", Op: "eq", Value: 1}}
+		if _, err := model.CompileQuery(spec, Supplier{}); err == nil {
+			t.Errorf("CompileQuery fail. Unknown field accepted \n")
+		}
+
+		setCreator(mockStub, "Org1MSP")
+		if _, err := controller.UpdateConfig(util.CurrentContext(), `{"AdminMSPs":["Org1MSP","Org2MSP"],"Validation":"lenient","Features":{"Events":true},"QueryAccess":{"Org3MSP":["Retailer"]}}`); err != nil {
+			t.Fatalf("UpdateConfig fail. Error %s \n", err.Error())
+		}
+		setCreator(mockStub, "Org3MSP")
+		if _, err := controller.QueryAssets(util.CurrentContext(), model.QuerySpec{AssetType: "Supplier"}); err == nil || !strings.HasPrefix(err.Error(), "Access denied") {
+			t.Errorf("QueryAssets fail. Query outside the allowed asset types not denied. Error %v \n", err)
+		}
+		if _, err := controller.QueryAssets(util.CurrentContext(), model.QuerySpec{AssetType: "Retailer"}); err != nil && strings.HasPrefix(err.Error(), "Access denied") {
+			t.Errorf("QueryAssets fail. Allowed asset type denied. Error %v \n", err)
+		}
+		if _, err := controller.ExecuteQuery(util.CurrentContext(), `{"selector":{}}`); err == nil {
+			t.Errorf("ExecuteQuery fail. Raw query by a non admin accepted \n")
+		}
+		if _, err := controller.GetReport(util.CurrentContext(), model.ReportSpec{AssetType: "Invoice", GroupBy: []string{"InvoiceId"}, Sum: []string{"Amount"}}); err == nil || !strings.HasPrefix(err.Error(), "Access denied") {
+			t.Errorf("GetReport fail. Report outside the allowed asset types not denied. Error %v \n", err)
+		}
+		if _, err := controller.GetRawMaterialReport(util.CurrentContext(), nil, nil); err == nil || !strings.HasPrefix(err.Error(), "Access denied") {
+			t.Errorf("GetRawMaterialReport fail. Report outside the allowed asset types not denied. Error %v \n", err)
+		}
+		if _, err := controller.GetRetailSalesReport(util.CurrentContext(), nil, nil); err != nil {
+			t.Errorf("GetRetailSalesReport fail. Allowed asset type denied. Error %v \n", err)
+		}
+		t.Logf("QueryAssets success. Result: %v \n", query)
+	})
+
+	t.Run("test method: transaction context", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid32")
+		setCreator(mockStub, "Org1MSP")
+		response := util.ExecuteMethod(controller, "GetConfig", mockStub, []string{})
+		var cfg model.Config
+		if response.Status != 200 || json.Unmarshal(response.Payload, &cfg) != nil || len(cfg.AdminMSPs) != 2 {
+			t.Errorf("ExecuteMethod fail. Context not injected. Response %v \n", response)
+		}
+		if response := util.ExecuteMethod(controller, "UpdateConfig", mockStub, []string{}); response.Status == 200 {
+			t.Errorf("ExecuteMethod fail. Missing argument accepted \n")
+		}
+
+		// a context over another stub sees its own ledger, whatever the global stub is
+		otherStub := shimtest.NewMockStub("Other Stub", mockchaincode)
+		otherStub.MockTransactionStart("Txid32")
+		other, err := controller.GetConfig(util.NewTxContext(otherStub))
+		if err != nil || len(other.AdminMSPs) != 0 {
+			t.Errorf("GetConfig fail. Context read the global stub. Config %v Error %v \n", other, err)
+		}
+		otherCtx := util.NewTxContext(otherStub)
+		if _, err := controller.GetSupplierById(otherCtx, "s"); err == nil {
+			t.Errorf("GetSupplierById fail. Context read the global stub \n")
+		}
+		if _, err := controller.GetSupplierById(util.CurrentContext(), "s"); err != nil {
+			t.Errorf("GetSupplierById fail. Error %s \n", err.Error())
+		}
+		if _, err := controller.GetConfig(otherCtx); err != nil || otherCtx.Config == nil || otherCtx.Config.(*model.Config).Validation != model.ValidationStrict {
+			t.Errorf("GetConfig fail. Config not kept in the context %v \n", otherCtx.Config)
+		}
+		if first, second := model.NextTxScopedId("ctx"), model.NextTxScopedId("ctx"); first == second || !strings.HasSuffix(first, "-Txid32-0") {
+			t.Errorf("NextTxScopedId fail. Ids %s %s \n", first, second)
+		}
+		t.Logf("Transaction context success. Result: %v \n", cfg)
+	})
+
+	t.Run("test method: GetMetadata", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid33")
+		parsed, err := params.Parse(".", "Controller")
+		if err != nil || len(parsed) != len(methodParams) {
+			t.Errorf("GetMetadata fail. Parameter names out of date, run go generate. Error %v \n", err)
+		}
+		for method, names := range parsed {
+			if strings.Join(methodParams[method], ",") != strings.Join(names, ",") {
+				t.Errorf("GetMetadata fail. Parameter names of %s out of date, run go generate \n", method)
+			}
+		}
+		metadata, err := controller.GetMetadata()
+		if err != nil {
+			t.Fatalf("GetMetadata fail. Error %s \n", err.Error())
+		}
+		transactions := make(map[string]util.TransactionMetadata)
+		for _, transaction := range metadata.Contracts[util.ChaincodeName].Transactions {
+			transactions[transaction.Name] = transaction
+		}
+		getRaw := transactions["GetRawMaterialFromSupplier"]
+		if len(getRaw.Parameters) != 3 || getRaw.Parameters[1].Name != "supplierId" || getRaw.Parameters[2].Schema["type"] != "integer" {
+			t.Errorf("GetMetadata fail. GetRawMaterialFromSupplier described as %v \n", getRaw)
+		}
+		if update := transactions["UpdateConfig"]; len(update.Parameters) != 1 || update.Parameters[0].Name != "config" {
+			t.Errorf("GetMetadata fail. Context parameter listed in %v \n", update)
+		}
+		create := transactions["CreateSupplier"]
+		if len(create.Parameters) != 1 || create.Parameters[0].Schema["$ref"] != util.MetadataSchemaPrefix+"Supplier" ||
+			!reflect.DeepEqual(create.Parameters[0].Schema["required"], []string{"SupplierId"}) {
+			t.Errorf("GetMetadata fail. CreateSupplier described as %v \n", create)
+		}
+		supplier := metadata.Components.Schemas["Supplier"]
+		properties, _ := supplier["properties"].(validators.Schema)
+		active, _ := properties["Active"].(validators.Schema)
+		supplierId, _ := properties["SupplierId"].(validators.Schema)
+		// SupplierId, License and the embedded assets fail validation when absent, so they are required wherever Supplier is
+		if required, _ := supplier["required"].([]string); strings.Join(required, ",") != "SupplierId,Retailer,License,Account" ||
+			active["default"] != true || supplierId["x-validate"] != "string,regexp=^[a-zA-Z]$" {
+			t.Errorf("GetMetadata fail. Supplier schema %v \n", supplier)
+		}
+		if _, err := json.Marshal(metadata); err != nil {
+			t.Errorf("GetMetadata fail. Marshal error %s \n", err.Error())
+		}
+		t.Logf("GetMetadata success. Result: %d transactions \n", len(transactions))
+	})
+
+	t.Run("test method: JSON Schema", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid34")
+		result := util.ExecuteMethod(controller, "GetSupplierSchema", mockStub, []string{})
+		var exported map[string]interface{}
+		if result.Status != 200 || json.Unmarshal(result.Payload, &exported) != nil || exported["$schema"] != validators.SchemaDialect {
+			t.Fatalf("JSON Schema fail. GetSupplierSchema returned %d %s \n", result.Status, result.Message)
+		}
+		bank := `{"License":"ab"}`
+		customer := `{"PhoneNumber":"555-123-4567","Bank_details":` + bank + `}`
+		retailer := `{"RetailerId":"r","ProductsOrdered":1,"Items":[1,2],"Domain":"https://example.com/products/catalogue","Customer":` + customer + `}`
+		samples := []struct {
+			schema    validators.Schema
+			prototype func() interface{}
+			document  string
+		}{
+			{controllerSchema(controller.GetSupplierSchema), func() interface{} { return new(Supplier) },
+				`{"SupplierId":"s","License":"ab","Retailer":` + retailer + `,"Account":{"License":"abc"},"ExpiryDate":"2021-01-01"}`},
+			{controllerSchema(controller.GetSupplierSchema), func() interface{} { return new(Supplier) },
+				`{"SupplierId":"s","License":"ab","Account":{"License":"abc"}}`},
+			{controllerSchema(controller.GetSupplierSchema), func() interface{} { return new(Supplier) },
+				`{"SupplierId":"s","License":"abcde","Retailer":` + retailer + `,"Account":{"License":"abc"}}`},
+			{controllerSchema(controller.GetSupplierSchema), func() interface{} { return new(Supplier) },
+				`{"SupplierId":"ss","License":"ab","Retailer":` + retailer + `,"Account":{"License":"abc"}}`},
+			{controllerSchema(controller.GetSupplierSchema), func() interface{} { return new(Supplier) },
+				`{"SupplierId":"s","License":"ab","Retailer":` + retailer + `,"Account":{"License":"abc","ExpiryDate":"01/01/2021"}}`},
+			{controllerSchema(controller.GetSupplierSchema), func() interface{} { return new(Supplier) },
+				`{"SupplierId":"s","License":"ab","Retailer":` + retailer + `,"Account":{"License":"abc"},"RawMaterialAvailable":-1}`},
+			{controllerSchema(controller.GetSupplierSchema), func() interface{} { return new(Supplier) },
+				`{"SupplierId":"s","License":"ab","Retailer":` + retailer + `,"Account":{"License":"abc"},"RawMaterialAvailable":1.5}`},
+			{controllerSchema(controller.GetRetailerSchema), func() interface{} { return new(Retailer) }, retailer},
+			{controllerSchema(controller.GetRetailerSchema), func() interface{} { return new(Retailer) },
+				`{"RetailerId":"r","ProductsOrdered":1,"Items":[1,2,3,4,5,6],"Domain":"https://example.com/products/catalogue","Customer":` + customer + `}`},
+			{controllerSchema(controller.GetRetailerSchema), func() interface{} { return new(Retailer) },
+				`{"RetailerId":"r","ProductsOrdered":1,"Items":[1],"Domain":"example.com/products/catalogue/shop/home","Customer":` + customer + `}`},
+			{controllerSchema(controller.GetRetailerSchema), func() interface{} { return new(Retailer) },
+				`{"RetailerId":"r","Items":[1],"Domain":"https://example.com/products/catalogue","Customer":` + customer + `}`},
+			{controllerSchema(controller.GetRetailerSchema), func() interface{} { return new(Retailer) },
+				`{"RetailerId":"r","ProductsOrdered":1,"Items":[1],"Domain":"https://example.com/products/catalogue","Customer":{"PhoneNumber":"555-123-4567"}}`},
+			{controllerSchema(controller.GetRetailerSchema), func() interface{} { return new(Retailer) },
+				`{"RetailerId":"r","ProductsOrdered":1,"Items":[1],"Domain":"https://example.com/products/catalogue","Customer":` + customer + `}`},
+			{controllerSchema(controller.GetRetailerSchema), func() interface{} { return new(Retailer) },
+				`{"RetailerId":"r","ProductsOrdered":1,"Items":[1],"Domain":"https://example.com/products/catalogue","Customer":{"PhoneNumber":"call me"}}`},
+			{controllerSchema(controller.GetManufacturerSchema), func() interface{} { return new(Manufacturer) },
+				`{"ManufacturerId":"m","CompletionDate":"2020-06-27","Account":{"License":"ab"},"Bank_details":` + bank + `,"RawMaterialAvailable":8}`},
+			{controllerSchema(controller.GetManufacturerSchema), func() interface{} { return new(Manufacturer) },
+				`{"ManufacturerId":"m","CompletionDate":"2020-06-28T02:30:55Z","Account":{"License":"ab"},"Bank_details":` + bank + `}`},
+			{controllerSchema(controller.GetManufacturerSchema), func() interface{} { return new(Manufacturer) },
+				`{"ManufacturerId":"m","CompletionDate":"2020-06-27T12:00:00+02:00","Account":{"License":"ab"},"Bank_details":` + bank + `}`},
+			{controllerSchema(controller.GetManufacturerSchema), func() interface{} { return new(Manufacturer) },
+				`{"ManufacturerId":"m","CompletionDate":"2020-06-27","Account":{"License":"ab"},"Bank_details":` + bank + `,"RawMaterialAvailable":9}`},
+			{controllerSchema(controller.GetManufacturerSchema), func() interface{} { return new(Manufacturer) },
+				`{"ManufacturerId":"m","CompletionDate":"2020-06-27","Account":{"License":"ab"},"Bank_details":{"License":"ab","ExpiryDate":"2020-07-01"}}`},
+			{controllerSchema(controller.GetDistributorSchema), func() interface{} { return new(Distributor) },
+				`{"DistributorId":"d","ProductsShipped":3,"MailId":"orders@example.com"}`},
+			{controllerSchema(controller.GetDistributorSchema), func() interface{} { return new(Distributor) },
+				`{"DistributorId":"d","ProductsShipped":3,"MailId":"orders"}`},
+			{controllerSchema(controller.GetDistributorSchema), func() interface{} { return new(Distributor) },
+				`{"DistributorId":"d","MailId":"orders@example.com"}`},
+			{controllerSchema(controller.GetPurchaseOrderSchema), func() interface{} { return new(PurchaseOrder) },
+				`{"PurchaseOrderId":"p","Buyer":"b","Seller":"s","Lines":[{"Product":"x","Quantity":2}]}`},
+			{controllerSchema(controller.GetPurchaseOrderSchema), func() interface{} { return new(PurchaseOrder) },
+				`{"PurchaseOrderId":"p","Buyer":"b","Seller":"s","Lines":[]}`},
+			{controllerSchema(controller.GetPurchaseOrderSchema), func() interface{} { return new(PurchaseOrder) },
+				`{"PurchaseOrderId":"p","Buyer":"b","Seller":"s","Lines":[{"Product":"x"}]}`},
+			{controllerSchema(controller.GetPurchaseOrderSchema), func() interface{} { return new(PurchaseOrder) },
+				`{"PurchaseOrderId":"p","Buyer":"b","Lines":[{"Product":"x","Quantity":2}]}`},
+			{controllerSchema(controller.GetInvoiceSchema), func() interface{} { return new(Invoice) },
+				`{"InvoiceId":"i","MovementId":"m","UnitPrice":"12.50","Amount":25}`},
+			{controllerSchema(controller.GetInvoiceSchema), func() interface{} { return new(Invoice) },
+				`{"InvoiceId":"i","MovementId":"m","UnitPrice":-1}`},
+			{controllerSchema(controller.GetInvoiceSchema), func() interface{} { return new(Invoice) },
+				`{"InvoiceId":"i","MovementId":"m","UnitPrice":"-0.01"}`},
+			{controllerSchema(controller.GetInvoiceSchema), func() interface{} { return new(Invoice) },
+				`{"InvoiceId":"i","MovementId":"m","UnitPrice":1e3}`},
+			{controllerSchema(controller.GetInvoiceSchema), func() interface{} { return new(Invoice) },
+				`{"InvoiceId":"i","MovementId":"m","UnitPrice":"1.125"}`},
+			{controllerSchema(controller.GetInvoiceSchema), func() interface{} { return new(Invoice) },
+				`{"InvoiceId":"i","MovementId":"m","UnitPrice":"1","Amount":"ten"}`},
+		}
+		accepted := 0
+		for _, sample := range samples {
+			schemaErr := sample.schema.Validate([]byte(sample.document))
+			goErr := util.UnmarshalAsset(sample.document, sample.prototype())
+			if (schemaErr == nil) != (goErr == nil) {
+				t.Errorf("JSON Schema fail. Document %s schema error %v validators error %v \n", sample.document, schemaErr, goErr)
+			}
+			if goErr == nil {
+				accepted++
+			}
+		}
+		if accepted == 0 || accepted == len(samples) {
+			t.Errorf("JSON Schema fail. %d of %d samples accepted \n", accepted, len(samples))
+		}
+		t.Logf("JSON Schema success. Result: %d of %d samples accepted by both \n", accepted, len(samples))
+	})
+
+	t.Run("test method: named arguments", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid35")
+		if problems := util.CheckMethodDefaults(); len(problems) != 0 {
+			t.Errorf("Named arguments fail. Defaults %v \n", problems)
+		}
+		response := util.ExecuteMethod(controller, "GetSupplierById"+util.NamedArgsSuffix, mockStub, []string{`{"id":"v"}`})
+		var supplier Supplier
+		if response.Status != 200 || json.Unmarshal(response.Payload, &supplier) != nil || supplier.SupplierId != "v" {
+			t.Errorf("Named arguments fail. GetSupplierById returned %d %s \n", response.Status, response.Message)
+		}
+		for _, args := range []string{`{}`, `{"id":"v","Id":"v"}`, `["v"]`} {
+			if response := util.ExecuteMethod(controller, "GetSupplierById"+util.NamedArgsSuffix, mockStub, []string{args}); response.Status == 200 {
+				t.Errorf("Named arguments fail. Arguments %s accepted \n", args)
+			}
+		}
+		if response := util.ExecuteMethod(controller, "UpdateConfig"+util.NamedArgsSuffix, mockStub, []string{`{"ctx":{}}`}); response.Status == 200 {
+			t.Errorf("Named arguments fail. Context given by name \n")
+		}
+		positional := util.ExecuteMethod(controller, "GetLicensesExpiringWithin", mockStub, []string{"30"})
+		for _, response := range []peer.Response{
+			util.ExecuteMethod(controller, "GetLicensesExpiringWithin"+util.NamedArgsSuffix, mockStub, []string{`{}`}),
+			util.ExecuteMethod(controller, "getLicensesExpiringWithin"+util.NamedArgsSuffix, mockStub, []string{`{"days":30}`}),
+			util.ExecuteMethod(controller, "GetLicensesExpiringWithin"+util.NamedArgsSuffix, mockStub, []string{`{"days":"30"}`}),
+			util.ExecuteMethod(controller, "GetLicensesExpiringWithin", mockStub, []string{}),
+		} {
+			if positional.Status != 200 || response.Status != 200 || string(response.Payload) != string(positional.Payload) {
+				t.Errorf("Named arguments fail. Response %d %s, positional %d %s \n", response.Status, response.Message, positional.Status, positional.Message)
+			}
+		}
+		if response := util.ExecuteMethod(controller, "GetLicensesExpiringWithin"+util.NamedArgsSuffix, mockStub, []string{`{"days":-1}`}); response.Status == 200 {
+			t.Errorf("Named arguments fail. Negative days accepted \n")
+		}
+		rows := util.ExecuteMethod(controller, "GetRawMaterialReport"+util.NamedArgsSuffix, mockStub, []string{`{"filter":{"SupplierId":"u"}}`})
+		if rows.Status != 200 {
+			t.Errorf("Named arguments fail. GetRawMaterialReport returned %s \n", rows.Message)
+		}
+		t.Logf("Named arguments success. Result: %s \n", string(response.Payload))
+	})
+
+	t.Run("test method: argument conversion", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid36")
+		probe := new(argProbe)
+		call := func(method string, args ...string) (string, bool) {
+			response := util.ExecuteMethod(probe, method, mockStub, args)
+			if response.Status != 200 {
+				return response.Message, false
+			}
+			return string(response.Payload), true
+		}
+		if result, ok := call("Optional", ""); !ok || result != `"none"` {
+			t.Errorf("Argument conversion fail. Empty pointer argument gave %s \n", result)
+		}
+		if result, ok := call("Optional", "5"); !ok || result != "5" {
+			t.Errorf("Argument conversion fail. Pointer argument gave %s \n", result)
+		}
+		supplier := `{"SupplierId":"a","License":"ab","Account":{"License":"ab"},"Retailer":{"Items":[1],"Domain":"https://example.com/products/catalogue",` +
+			`"Customer":{"PhoneNumber":"555-123-4567","Bank_details":{"License":"ab"}}}}`
+		if result, ok := call("Suppliers", `[`+supplier+`,{"License":"ab"}]`); ok || !strings.Contains(result, "element 1") {
+			t.Errorf("Argument conversion fail. Supplier without id gave %s \n", result)
+		}
+		if result, ok := call("Suppliers", `[`+supplier+`]`); !ok || !strings.Contains(result, `"Status":"active"`) {
+			t.Errorf("Argument conversion fail. Supplier elements gave %s \n", result)
+		}
+		if result, ok := call("Accounts", `{"a":{"License":"ab"},"b":null}`); !ok || !strings.Contains(result, `"Active":true`) || !strings.Contains(result, `"b":null`) {
+			t.Errorf("Argument conversion fail. Account values gave %s \n", result)
+		}
+		if result, ok := call("Accounts", `{"a":{"License":"a"}}`); ok {
+			t.Errorf("Argument conversion fail. Invalid account value accepted %s \n", result)
+		}
+		if result, ok := call("Wait", "90s", "5"); !ok || result != `"1m30s 5"` {
+			t.Errorf("Argument conversion fail. Duration gave %s \n", result)
+		}
+		for _, args := range [][]string{{"5", "5"}, {"1m", "5m"}} {
+			if result, ok := call("Wait", args...); ok {
+				t.Errorf("Argument conversion fail. Arguments %v gave %s \n", args, result)
+			}
+		}
+		for _, args := range [][]string{{"2021-01-01", "12.50"}, {`"2021-01-01T00:00:00Z"`, `"12.50"`}} {
+			if result, ok := call("Due", args...); !ok || result != `"2021-01-01 12.50"` {
+				t.Errorf("Argument conversion fail. Arguments %v gave %s \n", args, result)
+			}
+		}
+		t.Logf("Argument conversion success. \n")
+	})
+
+	t.Run("test method: method signatures", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid37")
+		if problems := util.CheckController(controller); len(problems) > 0 {
+			t.Errorf("Method signatures fail. Controller rejected: %s \n", util.Problems(problems).Error())
+		}
+		problems := util.Problems(util.CheckController(new(badProbe))).Error()
+		if !strings.Contains(problems, "Callback: parameter 1") || !strings.Contains(problems, "Pair: returns") || !strings.Contains(problems, "Late: the transaction context") {
+			t.Errorf("Method signatures fail. Invalid methods not reported: %s \n", problems)
+		}
+		if response := util.ExecuteMethod(new(badProbe), "Pair", mockStub, []string{}); response.Status == 200 {
+			t.Errorf("Method signatures fail. Invalid method called \n")
+		}
+		probe := new(argProbe)
+		if response := util.ExecuteMethod(probe, "Check", mockStub, []string{"true"}); response.Status != 200 || len(response.Payload) != 0 {
+			t.Errorf("Method signatures fail. Error only method returned %v \n", response)
+		}
+		if response := util.ExecuteMethod(probe, "Check", mockStub, []string{"false"}); response.Status == 200 {
+			t.Errorf("Method signatures fail. Error not reported \n")
+		}
+		if response := util.ExecuteMethod(probe, "Echo", mockStub, []string{"x"}); response.Status != 200 || string(response.Payload) != `"x"` {
+			t.Errorf("Method signatures fail. Value only method returned %v \n", response)
+		}
+		var all []Supplier
+		if _, err := model.GetByRange("", "", &all); err != nil || len(all) < 2 {
+			t.Fatalf("Method signatures fail. Suppliers %d Error %v \n", len(all), err)
+		}
+		var paged []Supplier
+		bookmark := ""
+		for pages := 0; pages <= len(all); pages++ {
+			response := util.ExecuteMethod(controller, "GetSupplierPage", mockStub, []string{"1", bookmark})
+			var page struct {
+				Results  []Supplier
+				Bookmark string
+			}
+			if response.Status != 200 || json.Unmarshal(response.Payload, &page) != nil || len(page.Results) != 1 {
+				t.Fatalf("Method signatures fail. GetSupplierPage returned %d %s \n", response.Status, response.Message)
+			}
+			paged = append(paged, page.Results...)
+			if bookmark = page.Bookmark; bookmark == "" {
+				break
+			}
+		}
+		if len(paged) != len(all) || paged[0].SupplierId != all[0].SupplierId {
+			t.Errorf("Method signatures fail. Paged %d of %d suppliers \n", len(paged), len(all))
+		}
+		t.Logf("Method signatures success. Result: %d pages \n", len(paged))
+	})
+
+	t.Run("test method: self-check", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid38")
+		chaincodetest.RequireSelfCheck(t, SelfCheck)
+		problems := model.CheckAssetType(badAsset{})
+		expected := []string{"no field is tagged id", `expected "fffffefe.badAsset"`, "Code: unknown validator lenght",
+			`Code: regexp "[a-" does not compile`, `Issued: before needs a date, given "soon"`,
+			"Price: min does not apply to decimals", "Lines.Count: unknown validator positve"}
+		for _, problem := range expected {
+			if !strings.Contains(strings.Join(problems, "; "), problem) {
+				t.Errorf("Self-check fail. Problem %s not reported in %v \n", problem, problems)
+			}
+		}
+		if problems := util.CheckController(new(badProbe)); len(problems) < 3 || !strings.Contains(strings.Join(problems, "; "), "run go generate") {
+			t.Errorf("Self-check fail. Controller problems %v \n", problems)
+		}
+		t.Logf("Self-check success. Result: %d problems of badAsset \n", len(problems))
+	})
+
+	t.Run("test method: asset type registry", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid39")
+		var suppliers []*Supplier
+		if _, err := model.GetByRange("", string(utf8.MaxRune), &suppliers); err != nil || len(suppliers) == 0 {
+			t.Fatalf("Asset type registry fail. Suppliers by pointer %d Error %v \n", len(suppliers), err)
+		}
+		id := suppliers[0].SupplierId
+		asset, err := model.GetAnyById(id)
+		if supplier, ok := asset.(*Supplier); err != nil || !ok || supplier.SupplierId != id {
+			t.Errorf("Asset type registry fail. GetAnyById returned %T Error %v \n", asset, err)
+		}
+		if _, err := model.Get(id, new(Customer)); err == nil {
+			t.Errorf("Asset type registry fail. Supplier %s read as a customer \n", id)
+		}
+		if asset, err := model.NewAsset("fffffefe.Recall"); err != nil || reflect.TypeOf(asset) != reflect.TypeOf(&Recall{}) {
+			t.Errorf("Asset type registry fail. NewAsset returned %T Error %v \n", asset, err)
+		}
+		if _, err := model.NewAsset("Bank_details"); err == nil {
+			t.Errorf("Asset type registry fail. Bank_details registered \n")
+		}
+		// the records carry the AssetType of their struct tags, whatever the chaincode is deployed as
+		util.ChaincodeName = "renamed"
+		defer func() { util.ChaincodeName = "fffffefe" }()
+		var renamed []Supplier
+		if _, err := model.GetByRange("", string(utf8.MaxRune), &renamed); err != nil || len(renamed) != len(suppliers) {
+			t.Errorf("Asset type registry fail. Renamed chaincode read %d of %d suppliers \n", len(renamed), len(suppliers))
+		}
+		t.Logf("Asset type registry success. Result: %d suppliers \n", len(suppliers))
+	})
+
+	t.Run("test method: composite ids", func(t *testing.T) {
+		mockStub.MockTransactionStart("Txid40")
+		due, _ := time.Parse(date.CustomDateLayout, "2020-06-27")
+		line := orderLine{Retailer: "r~1", OrderNumber: 42, Due: date.Date{Time: due}}
+		if _, err := model.Save(&line); err != nil {
+			t.Fatalf("Composite ids fail. Save Error %s \n", err.Error())
+		}
+		key, err := model.CompositeId("r~1", 42, date.Date{Time: due})
+		if err != nil || key != "r%7E1~42~2020-06-27" {
+			t.Errorf("Composite ids fail. Key %s Error %v \n", key, err)
+		}
+		var stored orderLine
+		if _, err := model.Get(key, &stored); err != nil || stored.OrderNumber != 42 {
+			t.Errorf("Composite ids fail. Get %s returned %v Error %v \n", key, stored, err)
+		}
+		if _, err := model.Save(&orderLine{Retailer: "r~1", OrderNumber: 42, Due: date.Date{Time: due}}); err == nil {
+			t.Errorf("Composite ids fail. Duplicate composite id saved \n")
+		}
+		if _, err := model.Save(&orderLine{OrderNumber: 43}); err == nil {
+			t.Errorf("Composite ids fail. Empty id part saved \n")
+		}
+		first, second := &note{Text: "a"}, &note{Text: "b"}
+		if _, err := model.Save(first); err != nil {
+			t.Fatalf("Composite ids fail. Generated id Error %s \n", err.Error())
+		}
+		if _, err := model.Save(second); err != nil || first.NoteId == second.NoteId || !strings.HasPrefix(first.NoteId, "note-Txid40-") {
+			t.Errorf("Composite ids fail. Generated ids %s %s Error %v \n", first.NoteId, second.NoteId, err)
+		}
+		if _, err := model.Get(second.NoteId, new(note)); err != nil {
+			t.Errorf("Composite ids fail. Generated id %s not stored \n", second.NoteId)
+		}
+		problems := strings.Join(model.CheckAssetType(badIds{}), "; ")
+		for _, problem := range []string{"id field Weight: id values of type float64 cannot be encoded", "generated id field Serial is not a string", `id field Code is tagged "yes"`} {
+			if !strings.Contains(problems, problem) {
+				t.Errorf("Composite ids fail. Problem %s not reported in %s \n", problem, problems)
+			}
+		}
+		t.Logf("Composite ids success. Result: %s %s \n", key, first.NoteId)
+	})
+}
+
+// argProbe has methods taking the argument types ExecuteMethod converts
+type argProbe struct{}
+
+func (p *argProbe) Optional(limit *int) (interface{}, error) {
+	if limit == nil {
+		return "none", nil
+	}
+	return *limit, nil
+}
+
+func (p *argProbe) Suppliers(assets []Supplier) ([]Supplier, error) {
+	return assets, nil
+}
+
+func (p *argProbe) Accounts(assets map[string]*Account) (map[string]*Account, error) {
+	return assets, nil
+}
+
+func (p *argProbe) Wait(wait time.Duration, count int64) (string, error) {
+	return wait.String() + " " + strconv.FormatInt(count, 10), nil
+}
+
+func (p *argProbe) Due(due date.Date, amount decimal.Decimal) (string, error) {
+	return due.Format(date.CustomDateLayout) + " " + amount.String(), nil
+}
+
+func (p *argProbe) Check(ok bool) error {
+	if !ok {
+		return errors.New("check failed")
+	}
+	return nil
+}
+
+func (p *argProbe) Echo(value string) string {
+	return value
+}
+
+// badProbe has methods ExecuteMethod cannot call
+type badProbe struct{}
+
+func (p *badProbe) Pair() (int, int) {
+	return 1, 2
+}
+
+func (p *badProbe) Callback(callback func()) error {
+	return nil
+}
+
+func (p *badProbe) Late(id string, ctx *util.TxContext) error {
+	return nil
+}
+
+// badAsset is an asset type with the mistakes the self-check reports
+type badAsset struct {
+	AssetType string          `json:"AssetType" final:"fffffefe.Bad"`
+	Code      string          `json:"Code" validate:"string,lenght=3,regexp=[a-"`
+	Issued    date.Date       `json:"Issued" validate:"date,before=soon"`
+	Price     decimal.Decimal `json:"Price" validate:"decimal,min=0"`
+	Lines     []struct {
+		Count int `json:"Count" validate:"int,positve"`
+	} `json:"Lines"`
+}
+
+// orderLine is keyed by retailer, order number and due date
+type orderLine struct {
+	AssetType   string    `json:"AssetType" final:"fffffefe.orderLine"`
+	Retailer    string    `json:"Retailer" id:"true"`
+	OrderNumber int       `json:"OrderNumber" id:"true"`
+	Due         date.Date `json:"Due" id:"true"`
+}
+
+// note has no natural key, its id is generated on save
+type note struct {
+	AssetType string `json:"AssetType" final:"fffffefe.note"`
+	NoteId    string `json:"NoteId" id:"generated"`
+	Text      string `json:"Text"`
+}
+
+// badIds has id fields Save cannot use
+type badIds struct {
+	AssetType string  `json:"AssetType" final:"fffffefe.badIds"`
+	Weight    float64 `json:"Weight" id:"true"`
+	Serial    int     `json:"Serial" id:"generated"`
+	Code      string  `json:"Code" id:"yes"`
+}
+
+func controllerSchema(getSchema func() (validators.Schema, error)) validators.Schema {
+	schema, _ := getSchema()
+	return schema
+}
+
+func TestCouchIndexes(t *testing.T) {
+	dir := filepath.Join("..", model.CouchIndexDir)
+	generated, err := ioutil.TempDir("", "couchindexes")
+	if err != nil {
+		t.Fatalf("TempDir fail. Error %s \n", err.Error())
+	}
+	defer os.RemoveAll(generated)
+	if err := WriteCouchIndexes(generated); err != nil {
+		t.Fatalf("WriteCouchIndexes fail. Error %s \n", err.Error())
+	}
+	generatedFiles, _ := filepath.Glob(filepath.Join(generated, "*.json"))
+	packagedFiles, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(generatedFiles) == 0 || len(generatedFiles) != len(packagedFiles) {
+		t.Errorf("Couch indexes fail. %d indexes generated, %d packaged, run go generate \n", len(generatedFiles), len(packagedFiles))
+	}
+	for _, path := range generatedFiles {
+		expected, _ := ioutil.ReadFile(path)
+		packaged, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(path)))
+		if err != nil || string(packaged) != string(expected) {
+			t.Errorf("Couch indexes fail. %s is not up to date, run go generate \n", filepath.Base(path))
+		}
+	}
+
+	// every index must serve a sort on each of its fields, selecting the fields before it by equality
+	for assetType, prototype := range queryableAssets {
+		for _, index := range model.CouchIndexes(prototype) {
+			fields := index.Index.Fields[1:]
+			for i, field := range fields {
+				spec := model.QuerySpec{AssetType: assetType, Sort: []model.QuerySort{{Field: field}}}
+				for _, previous := range fields[:i] {
+					spec.Filters = append(spec.Filters, model.QueryFilter{Field: previous, Op: "eq", Value: "x"})
+				}
+				if _, err := model.CompileQuery(spec, prototype); err != nil {
+					t.Errorf("Couch indexes fail. Sort on %s.%s Error %s \n", assetType, field, err.Error())
+				}
+			}
+		}
+	}
+	if _, err := model.CompileQuery(model.QuerySpec{AssetType: "Customer", Sort: []model.QuerySort{{Field: "CustomerId"}}}, Customer{}); err == nil {
+		t.Errorf("Couch indexes fail. Sort without an index accepted \n")
+	}
+}
+
+func mustUnmarshal(t *testing.T, document string, asset interface{}) {
+	if err := json.Unmarshal([]byte(document), asset); err != nil {
+		t.Fatalf("Invalid test document %s. Error %s \n", document, err.Error())
+	}
+}
+
+func withOwner(document string, mspID string) string {
+	return strings.TrimSuffix(document, "}") + `,"OwnerMSP":"` + mspID + `"}`
+}
+
+func distributorJSON(id string, productsToBeShipped int) string {
+	return `{"DistributorId":"` + id + `","ProductsToBeShipped":` + strconv.Itoa(productsToBeShipped) +
+		`,"ProductsShipped":3,"MailId":"dispatch@example.com"}`
+}
+
+func setCreator(stub *shimtest.MockStub, mspID string) {
+	creator, _ := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: []byte(mspID + "-user")})
+	stub.Creator = creator
+}
+
+func customerJSON(id string) string {
+	return `{"CustomerId":"` + id + `","Name":"Jane","PhoneNumber":"123-456-7890","Bank_details":{"License":"bd01"}}`
+}
+
+func retailerJSON(id string) string {
+	return `{"RetailerId":"` + id + `","ProductsOrdered":0,"Customer":` + customerJSON("c-"+id) +
+		`,"Items":[1],"Domain":"https://retailer.example.com/shop"}`
+}
+
+func supplierJSON(id string) string {
+	return `{"SupplierId":"` + id + `","RawMaterialAvailable":5,"License":"lic1","ExpiryDate":"2099-12-31","Active":true,` +
+		`"Retailer":` + retailerJSON("r-"+id) + `,"Account":{"License":"ac01","ExpiryDate":"2099-12-31","Active":true}}`
+}
+
+func manufacturerJSON(id string) string {
+	return `{"ManufacturerId":"` + id + `","CompletionDate":"2020-06-27","Bank_details":{"License":"bd01"},"Account":{"License":"ac01"}}`
 }